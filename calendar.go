@@ -0,0 +1,196 @@
+package flyaa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+	"golang.org/x/sync/errgroup"
+)
+
+type CalendarConfig struct {
+	Config
+	DateFrom    string
+	DateTo      string
+	Days        int
+	Concurrency int
+}
+
+type calendarResponse struct {
+	SearchMetadata struct {
+		Origin         string         `json:"origin"`
+		Destination    string         `json:"destination"`
+		DateFrom       string         `json:"date_from"`
+		DateTo         string         `json:"date_to"`
+		Passengers     int            `json:"passengers"`
+		PassengerTypes []aa.Passenger `json:"passenger_types"`
+		CabinClass     string         `json:"cabin_class"`
+	} `json:"search_metadata"`
+	Days []calendarDay `json:"days"`
+}
+
+type calendarDay struct {
+	Date             string   `json:"date"`
+	Flights          int      `json:"flights"`
+	CheapestCashUSD  *float64 `json:"cheapest_cash_usd"`
+	CheapestCashID   string   `json:"cheapest_cash_flight_id,omitempty"`
+	CheapestPoints   *int     `json:"cheapest_points"`
+	CheapestPointsID string   `json:"cheapest_points_flight_id,omitempty"`
+	BestCPP          *float64 `json:"best_cpp"`
+	BestCPPID        string   `json:"best_cpp_flight_id,omitempty"`
+}
+
+// maxCalendarDays is the maximum number of days of a calendar search.
+const maxCalendarDays = 62
+
+// Calendar runs the cash and award searches for every date of a range and
+// prints the cheapest cash fare, the cheapest award and the best CPP per day.
+func Calendar(ctx context.Context, cfg *CalendarConfig) error {
+	// Validate input
+	if cfg.BaseURL == "" && cfg.Replay == "" {
+		return fmt.Errorf("base URL is required")
+	}
+	var from, to time.Time
+	if cfg.DateFrom != "" || cfg.DateTo != "" {
+		var err error
+		from, err = time.Parse("2006-01-02", cfg.DateFrom)
+		if err != nil {
+			return fmt.Errorf("date from must be in YYYY-MM-DD format: %w", err)
+		}
+		to, err = time.Parse("2006-01-02", cfg.DateTo)
+		if err != nil {
+			return fmt.Errorf("date to must be in YYYY-MM-DD format: %w", err)
+		}
+	} else {
+		date, err := time.Parse("2006-01-02", cfg.Date)
+		if err != nil {
+			return fmt.Errorf("flight date must be in YYYY-MM-DD format: %w", err)
+		}
+		if cfg.Days < 0 {
+			return fmt.Errorf("days must be positive")
+		}
+		from = date.AddDate(0, 0, -cfg.Days)
+		to = date.AddDate(0, 0, cfg.Days)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("date to %s is before date from %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+	var dates []string
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d.Format("2006-01-02"))
+	}
+	if len(dates) > maxCalendarDays {
+		return fmt.Errorf("date range can't be longer than %d days", maxCalendarDays)
+	}
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	// Create service client
+	svc, err := newClient(&cfg.Config)
+	if err != nil {
+		return err
+	}
+	defer logProxyStats(svc)
+	st, err := openStore(&cfg.Config)
+	if err != nil {
+		return err
+	}
+	defer closeStore(st)
+
+	// Search every date concurrently, keeping the flights of both searches
+	params := cfg.SearchParams
+	params.Client = svc
+	params.Store = st
+	params.Source = "calendar"
+	params.ReturnDate = ""
+	params.Legs = nil
+	params.Join = joinFull
+	params.Sort = nil
+	params.Limit = 0
+	days := make([]calendarDay, len(dates))
+	results := make([]*Result, len(dates))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+	for i, date := range dates {
+		g.Go(func() error {
+			p := params
+			p.Date = date
+			r, err := Search(ctx, p)
+			if errors.Is(err, aa.ErrNoAvailability) {
+				days[i] = calendarDay{Date: date}
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", date, err)
+			}
+			results[i] = r
+			days[i] = newCalendarDay(date, r.Flights)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	// Print response
+	var resp calendarResponse
+	meta := SearchMetadata{
+		Origin:      strings.ToUpper(cfg.Origin),
+		Destination: strings.ToUpper(cfg.Destination),
+		CabinClass:  strings.ToLower(cfg.CabinClass),
+	}
+	for _, r := range results {
+		if r != nil {
+			meta = r.SearchMetadata
+			break
+		}
+	}
+	resp.SearchMetadata.Origin = meta.Origin
+	resp.SearchMetadata.Destination = meta.Destination
+	resp.SearchMetadata.DateFrom = dates[0]
+	resp.SearchMetadata.DateTo = dates[len(dates)-1]
+	resp.SearchMetadata.Passengers = meta.Passengers
+	resp.SearchMetadata.PassengerTypes = meta.PassengerTypes
+	resp.SearchMetadata.CabinClass = meta.CabinClass
+	resp.Days = days
+
+	data, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't marshal response: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// newCalendarDay summarizes the flights of a date, combined with a full join.
+func newCalendarDay(date string, flights []aa.Flight) calendarDay {
+	day := calendarDay{Date: date}
+	for _, f := range flights {
+		day.Flights++
+		if f.CashPriceUSD != nil {
+			if day.CheapestCashUSD == nil || *f.CashPriceUSD < *day.CheapestCashUSD {
+				day.CheapestCashUSD = f.CashPriceUSD
+				day.CheapestCashID = f.ID()
+			}
+		}
+		if f.PointsRequired != nil && *f.PointsRequired > 0 {
+			if day.CheapestPoints == nil || *f.PointsRequired < *day.CheapestPoints {
+				day.CheapestPoints = f.PointsRequired
+				day.CheapestPointsID = f.ID()
+			}
+		}
+		if f.CPP != nil {
+			if day.BestCPP == nil || *f.CPP > *day.BestCPP {
+				day.BestCPP = f.CPP
+				day.BestCPPID = f.ID()
+			}
+		}
+	}
+	return day
+}
@@ -0,0 +1,57 @@
+package flyaa
+
+import (
+	"testing"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+)
+
+func TestNewCalendarDay(t *testing.T) {
+	both := testFlight("AA1", ptr(300.0), ptr(20000), ptr(5.6))
+	both.CPP = ptr(1.47)
+	tests := []struct {
+		name        string
+		flights     []aa.Flight
+		wantFlights int
+		wantCash    string
+		wantPoints  string
+		wantBestCPP string
+	}{
+		{name: "empty"},
+		{
+			name: "cash and award only flights are counted",
+			flights: []aa.Flight{
+				both,
+				testFlight("AA2", ptr(200.0), nil, nil),
+				testFlight("AA3", nil, ptr(15000), ptr(5.6)),
+			},
+			wantFlights: 3,
+			wantCash:    "AA2",
+			wantPoints:  "AA3",
+			wantBestCPP: "AA1",
+		},
+		{
+			name:        "cash only",
+			flights:     []aa.Flight{testFlight("AA2", ptr(200.0), nil, nil)},
+			wantFlights: 1,
+			wantCash:    "AA2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day := newCalendarDay("2025-12-15", tt.flights)
+			if day.Flights != tt.wantFlights {
+				t.Errorf("got %d flights, want %d", day.Flights, tt.wantFlights)
+			}
+			if day.CheapestCashID != tt.wantCash {
+				t.Errorf("got cheapest cash %q, want %q", day.CheapestCashID, tt.wantCash)
+			}
+			if day.CheapestPointsID != tt.wantPoints {
+				t.Errorf("got cheapest points %q, want %q", day.CheapestPointsID, tt.wantPoints)
+			}
+			if day.BestCPPID != tt.wantBestCPP {
+				t.Errorf("got best cpp %q, want %q", day.BestCPPID, tt.wantBestCPP)
+			}
+		})
+	}
+}
@@ -22,6 +22,8 @@ func main() {
 	// Launch command
 	cmd := cli.NewCommand(version, commit, date)
 	if err := cmd.ParseAndRun(ctx, os.Args[1:]); err != nil {
-		log.Fatal(err)
+		log.Println(err)
+		cancel()
+		os.Exit(cli.ExitCode(err))
 	}
 }
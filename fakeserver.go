@@ -0,0 +1,28 @@
+package flyaa
+
+import (
+	"context"
+
+	"github.com/igolaizola/flyaa/pkg/fakeaa"
+)
+
+type FakeServerConfig struct {
+	Addr     string
+	Scenario string
+}
+
+// FakeServer serves a fake AA search API with the responses of a scenario
+// until the context is canceled.
+func FakeServer(ctx context.Context, cfg *FakeServerConfig) error {
+	// Load scenario
+	scenario := fakeaa.DefaultScenario()
+	if cfg.Scenario != "" {
+		var err error
+		scenario, err = fakeaa.LoadScenario(cfg.Scenario)
+		if err != nil {
+			return err
+		}
+	}
+
+	return listenAndServe(ctx, cfg.Addr, "fake server", fakeaa.NewServer(scenario))
+}
@@ -0,0 +1,129 @@
+package flyaa
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+)
+
+// Filter restricts the flights of a search. Zero values don't filter. Stops,
+// times and durations are checked on every leg of round-trip and multi-city
+// flights, and flights without a price can't match a filter on that price.
+type Filter struct {
+	Nonstop bool
+	// MaxStops is the maximum number of stops of each leg. Nil doesn't
+	// filter.
+	MaxStops *int
+	// DepartAfter, DepartBefore and ArriveBefore are local times in HH:MM
+	// format. Arrivals on the following days are after any time.
+	DepartAfter  string
+	DepartBefore string
+	ArriveBefore string
+	// MaxDuration is the maximum duration of each leg.
+	MaxDuration time.Duration
+	// MaxCash and MaxPoints are per passenger prices.
+	MaxCash   float64
+	MaxPoints int
+	MinCPP    float64
+}
+
+// validate checks the times of the filter.
+func (f *Filter) validate() error {
+	if f.MaxStops != nil && *f.MaxStops < 0 {
+		return fmt.Errorf("max stops can't be negative")
+	}
+	times := []struct {
+		name  string
+		value string
+	}{
+		{"depart after", f.DepartAfter},
+		{"depart before", f.DepartBefore},
+		{"arrive before", f.ArriveBefore},
+	}
+	for _, t := range times {
+		if t.value == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", t.value); err != nil {
+			return fmt.Errorf("%s must be in HH:MM format: %w", t.name, err)
+		}
+	}
+	if f.MaxDuration < 0 || f.MaxCash < 0 || f.MaxPoints < 0 || f.MinCPP < 0 {
+		return fmt.Errorf("filter values can't be negative")
+	}
+	return nil
+}
+
+// apply returns the flights that match the filter.
+func (f *Filter) apply(flights []aa.Flight) []aa.Flight {
+	var filtered []aa.Flight
+	for _, fl := range flights {
+		if f.match(&fl) {
+			filtered = append(filtered, fl)
+		}
+	}
+	return filtered
+}
+
+// match returns whether a flight matches the filter. The filter must be
+// valid.
+func (f *Filter) match(fl *aa.Flight) bool {
+	// Check prices
+	if f.MaxCash > 0 && (fl.CashPriceUSD == nil || *fl.CashPriceUSD > f.MaxCash) {
+		return false
+	}
+	if f.MaxPoints > 0 && (fl.PointsRequired == nil || *fl.PointsRequired > f.MaxPoints) {
+		return false
+	}
+	if f.MinCPP > 0 && (fl.CPP == nil || *fl.CPP < f.MinCPP) {
+		return false
+	}
+
+	// Check every leg
+	legs := fl.Legs
+	if len(legs) == 0 {
+		legs = []aa.FlightLeg{{IsNonstop: fl.IsNonstop, Segments: fl.Segments}}
+	}
+	for _, leg := range legs {
+		if len(leg.Segments) == 0 {
+			return false
+		}
+		stops := len(leg.Segments) - 1
+		if f.Nonstop && (!leg.IsNonstop || stops > 0) {
+			return false
+		}
+		if f.MaxStops != nil && stops > *f.MaxStops {
+			return false
+		}
+		first, last := leg.Segments[0], leg.Segments[len(leg.Segments)-1]
+		departure := clockMinutes(first.DepartureTime)
+		arrival := clockMinutes(last.ArrivalTime) + last.ArrivalDayOffset*24*60
+		if f.DepartAfter != "" && departure < clockMinutes(f.DepartAfter) {
+			return false
+		}
+		if f.DepartBefore != "" && departure > clockMinutes(f.DepartBefore) {
+			return false
+		}
+		if f.ArriveBefore != "" && arrival > clockMinutes(f.ArriveBefore) {
+			return false
+		}
+		if f.MaxDuration > 0 {
+			start, err1 := time.Parse(time.RFC3339, first.DepartureDateTime)
+			end, err2 := time.Parse(time.RFC3339, last.ArrivalDateTime)
+			if err1 != nil || err2 != nil || end.Sub(start) > f.MaxDuration {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// clockMinutes returns the minutes since midnight of a time in HH:MM format.
+func clockMinutes(v string) int {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0
+	}
+	return t.Hour()*60 + t.Minute()
+}
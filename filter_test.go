@@ -0,0 +1,95 @@
+package flyaa
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+)
+
+func TestFilterMatch(t *testing.T) {
+	nonstop := []aa.FlightSegment{
+		{FlightNumber: "AA100", DepartureTime: "08:00", ArrivalTime: "16:30", DepartureDateTime: "2025-12-15T08:00:00-08:00", ArrivalDateTime: "2025-12-15T16:30:00-05:00"},
+	}
+	redeye := []aa.FlightSegment{
+		{FlightNumber: "AA200", DepartureTime: "22:00", ArrivalTime: "03:00", ArrivalDayOffset: 1, DepartureDateTime: "2025-12-15T22:00:00-08:00", ArrivalDateTime: "2025-12-16T03:00:00-06:00"},
+		{FlightNumber: "AA201", DepartureTime: "05:00", ArrivalTime: "09:30", DepartureDayOffset: 1, ArrivalDayOffset: 1, DepartureDateTime: "2025-12-16T05:00:00-06:00", ArrivalDateTime: "2025-12-16T09:30:00-05:00"},
+	}
+	flights := map[string]aa.Flight{
+		// 5h30m nonstop with every price
+		"nonstop": {IsNonstop: true, Segments: nonstop, CashPriceUSD: ptr(199.0), PointsRequired: ptr(12500), CPP: ptr(1.55)},
+		// 8h30m overnight flight with one stop and no prices
+		"redeye": {Segments: redeye},
+		// Round trip with the nonstop out and the redeye back
+		"roundtrip": {
+			Legs: []aa.FlightLeg{
+				{IsNonstop: true, Segments: nonstop},
+				{Segments: redeye},
+			},
+			CashPriceUSD:   ptr(398.0),
+			PointsRequired: ptr(25000),
+			CPP:            ptr(1.57),
+		},
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "empty", want: []string{"nonstop", "redeye", "roundtrip"}},
+		{name: "nonstop", filter: Filter{Nonstop: true}, want: []string{"nonstop"}},
+		{name: "max stops zero", filter: Filter{MaxStops: ptr(0)}, want: []string{"nonstop"}},
+		{name: "max stops one", filter: Filter{MaxStops: ptr(1)}, want: []string{"nonstop", "redeye", "roundtrip"}},
+		{name: "depart after", filter: Filter{DepartAfter: "09:00"}, want: []string{"redeye"}},
+		{name: "depart before", filter: Filter{DepartBefore: "08:00"}, want: []string{"nonstop"}},
+		{name: "arrive before next day arrivals", filter: Filter{ArriveBefore: "23:59"}, want: []string{"nonstop"}},
+		{name: "max duration", filter: Filter{MaxDuration: 6 * time.Hour}, want: []string{"nonstop"}},
+		{name: "max duration of every leg", filter: Filter{MaxDuration: 9 * time.Hour}, want: []string{"nonstop", "redeye", "roundtrip"}},
+		{name: "max cash excludes missing prices", filter: Filter{MaxCash: 500}, want: []string{"nonstop", "roundtrip"}},
+		{name: "max cash", filter: Filter{MaxCash: 200}, want: []string{"nonstop"}},
+		{name: "max points", filter: Filter{MaxPoints: 20000}, want: []string{"nonstop"}},
+		{name: "min cpp", filter: Filter{MinCPP: 1.56}, want: []string{"roundtrip"}},
+		{name: "combined", filter: Filter{MaxStops: ptr(1), MaxPoints: 30000, DepartBefore: "12:00"}, want: []string{"nonstop"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.validate(); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, name := range []string{"nonstop", "redeye", "roundtrip"} {
+				fl := flights[name]
+				if tt.filter.match(&fl) {
+					got = append(got, name)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "valid", filter: Filter{MaxStops: ptr(0), DepartAfter: "06:00", DepartBefore: "22:30", ArriveBefore: "23:59", MaxCash: 500}},
+		{name: "negative stops", filter: Filter{MaxStops: ptr(-1)}, wantErr: true},
+		{name: "invalid time", filter: Filter{DepartAfter: "6am"}, wantErr: true},
+		{name: "out of range time", filter: Filter{ArriveBefore: "25:00"}, wantErr: true},
+		{name: "negative duration", filter: Filter{MaxDuration: -time.Hour}, wantErr: true},
+		{name: "negative price", filter: Filter{MaxPoints: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package flyaa
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+)
+
+// flatFlight is a flight flattened to a single row, used by the CSV and
+// NDJSON outputs.
+type flatFlight struct {
+	FlightID            string
+	Origin              string
+	Destination         string
+	Date                string
+	ReturnDate          string
+	Route               string
+	Segments            string
+	IsNonstop           bool
+	Stops               int
+	DepartureDateTime   string
+	ArrivalDateTime     string
+	TotalDuration       string
+	CashPriceUSD        *float64
+	PointsRequired      *int
+	TaxesFeesUSD        *float64
+	CPP                 *float64
+	NoCPPReason         string
+	TotalCashPriceUSD   *float64
+	TotalPointsRequired *int
+	TotalTaxesFeesUSD   *float64
+	Passengers          int
+	CabinClass          string
+}
+
+// flatColumn is a column of the CSV and NDJSON outputs and the getter of its
+// value.
+type flatColumn struct {
+	name  string
+	value func(f *flatFlight) any
+}
+
+// flatColumns are the columns of the CSV and NDJSON outputs. Both the header
+// and the rows are built from it, so their order can't drift.
+var flatColumns = []flatColumn{
+	{"flight_id", func(f *flatFlight) any { return f.FlightID }},
+	{"origin", func(f *flatFlight) any { return f.Origin }},
+	{"destination", func(f *flatFlight) any { return f.Destination }},
+	{"date", func(f *flatFlight) any { return f.Date }},
+	{"return_date", func(f *flatFlight) any { return f.ReturnDate }},
+	{"route", func(f *flatFlight) any { return f.Route }},
+	{"segments", func(f *flatFlight) any { return f.Segments }},
+	{"is_nonstop", func(f *flatFlight) any { return f.IsNonstop }},
+	{"stops", func(f *flatFlight) any { return f.Stops }},
+	{"departure_datetime", func(f *flatFlight) any { return f.DepartureDateTime }},
+	{"arrival_datetime", func(f *flatFlight) any { return f.ArrivalDateTime }},
+	{"total_duration", func(f *flatFlight) any { return f.TotalDuration }},
+	{"cash_price_usd", func(f *flatFlight) any { return f.CashPriceUSD }},
+	{"points_required", func(f *flatFlight) any { return f.PointsRequired }},
+	{"taxes_fees_usd", func(f *flatFlight) any { return f.TaxesFeesUSD }},
+	{"cpp", func(f *flatFlight) any { return f.CPP }},
+	{"no_cpp_reason", func(f *flatFlight) any { return f.NoCPPReason }},
+	{"total_cash_price_usd", func(f *flatFlight) any { return f.TotalCashPriceUSD }},
+	{"total_points_required", func(f *flatFlight) any { return f.TotalPointsRequired }},
+	{"total_taxes_fees_usd", func(f *flatFlight) any { return f.TotalTaxesFeesUSD }},
+	{"passengers", func(f *flatFlight) any { return f.Passengers }},
+	{"cabin_class", func(f *flatFlight) any { return f.CabinClass }},
+}
+
+// flatHeader returns the names of the columns.
+func flatHeader() []string {
+	var names []string
+	for _, c := range flatColumns {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// newFlatFlight flattens a flight. Legs of round-trip and multi-city flights
+// are separated by " | " in the route and segments columns.
+func newFlatFlight(meta SearchMetadata, f aa.Flight) flatFlight {
+	flat := flatFlight{
+		FlightID:            f.ID(),
+		Origin:              meta.Origin,
+		Destination:         meta.Destination,
+		Date:                meta.Date,
+		ReturnDate:          meta.ReturnDate,
+		IsNonstop:           f.IsNonstop,
+		TotalDuration:       f.TotalDuration,
+		CashPriceUSD:        f.CashPriceUSD,
+		PointsRequired:      f.PointsRequired,
+		TaxesFeesUSD:        f.TaxesFeesUSD,
+		CPP:                 f.CPP,
+		NoCPPReason:         f.NoCPPReason,
+		TotalCashPriceUSD:   f.TotalCashPriceUSD,
+		TotalPointsRequired: f.TotalPointsRequired,
+		TotalTaxesFeesUSD:   f.TotalTaxesFeesUSD,
+		Passengers:          meta.Passengers,
+		CabinClass:          meta.CabinClass,
+	}
+	legs := f.Legs
+	if len(legs) == 0 {
+		legs = []aa.FlightLeg{{Segments: f.Segments}}
+	}
+	var routes, segments []string
+	for _, leg := range legs {
+		if len(leg.Segments) == 0 {
+			continue
+		}
+		cells := legCells(leg)
+		segments = append(segments, cells[0])
+		routes = append(routes, cells[1])
+		flat.Stops += len(leg.Segments) - 1
+	}
+	flat.Route = strings.Join(routes, " | ")
+	flat.Segments = strings.Join(segments, " | ")
+	if first := legs[0].Segments; len(first) > 0 {
+		flat.DepartureDateTime = first[0].DepartureDateTime
+	}
+	if last := legs[len(legs)-1].Segments; len(last) > 0 {
+		flat.ArrivalDateTime = last[len(last)-1].ArrivalDateTime
+	}
+	return flat
+}
+
+// record returns the CSV record of the flight.
+func (f *flatFlight) record() []string {
+	var record []string
+	for _, c := range flatColumns {
+		record = append(record, csvValue(c.value(f)))
+	}
+	return record
+}
+
+// MarshalJSON encodes the flight as an object with the columns in order.
+func (f *flatFlight) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, c := range flatColumns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value, err := json.Marshal(c.value(f))
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(strconv.Quote(c.name))
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// writeCSV writes a header and a row per flight. Missing prices are empty.
+func writeCSV(w io.Writer, r *Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(flatHeader()); err != nil {
+		return fmt.Errorf("couldn't write csv: %w", err)
+	}
+	for _, f := range r.Flights {
+		flat := newFlatFlight(r.SearchMetadata, f)
+		if err := cw.Write(flat.record()); err != nil {
+			return fmt.Errorf("couldn't write csv: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("couldn't write csv: %w", err)
+	}
+	return nil
+}
+
+// writeNDJSON writes a JSON object per flight and line. Missing prices are
+// null.
+func writeNDJSON(w io.Writer, r *Result) error {
+	enc := json.NewEncoder(w)
+	for _, f := range r.Flights {
+		flat := newFlatFlight(r.SearchMetadata, f)
+		if err := enc.Encode(&flat); err != nil {
+			return fmt.Errorf("couldn't write ndjson: %w", err)
+		}
+	}
+	return nil
+}
+
+// csvValue formats a column value. Missing prices are empty.
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case *int:
+		return csvInt(v)
+	case *float64:
+		return csvFloat(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func csvFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func csvInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
@@ -0,0 +1,83 @@
+package flyaa
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+)
+
+func TestFlatOutputs(t *testing.T) {
+	r := &Result{
+		SearchMetadata: SearchMetadata{Origin: "LAX", Destination: "JFK", Date: "2025-12-15", Passengers: 1, CabinClass: "main"},
+		Flights: []aa.Flight{
+			testFlight("AA100", ptr(199.0), ptr(12500), ptr(5.6)),
+			testFlight("AA200", ptr(150.0), nil, nil),
+		},
+	}
+
+	var csvBuf, jsonBuf bytes.Buffer
+	if err := writeCSV(&csvBuf, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeNDJSON(&jsonBuf, r); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&csvBuf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(r.Flights)+1 {
+		t.Fatalf("got %d csv rows, want %d", len(rows), len(r.Flights)+1)
+	}
+	header := rows[0]
+	if !slices.Equal(header, flatHeader()) {
+		t.Fatalf("got csv header %v, want %v", header, flatHeader())
+	}
+
+	// The NDJSON keys follow the CSV header and hold the same values
+	dec := json.NewDecoder(&jsonBuf)
+	for i, row := range rows[1:] {
+		var keys []string
+		values := map[string]any{}
+		if _, err := dec.Token(); err != nil {
+			t.Fatal(err)
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := tok.(string)
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+			keys = append(keys, key)
+			values[key] = v
+		}
+		if _, err := dec.Token(); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(keys, header) {
+			t.Fatalf("flight %d: got json keys %v, want %v", i, keys, header)
+		}
+		for j, name := range header {
+			var want string
+			switch v := values[name].(type) {
+			case nil:
+			case string:
+				want = v
+			default:
+				b, _ := json.Marshal(v)
+				want = string(b)
+			}
+			if row[j] != want {
+				t.Errorf("flight %d: %s: got csv %q, want %q", i, name, row[j], want)
+			}
+		}
+	}
+}
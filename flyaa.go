@@ -2,149 +2,198 @@ package flyaa
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"log"
 	"math"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/igolaizola/flyaa/pkg/aa"
-	"golang.org/x/sync/errgroup"
+	"github.com/igolaizola/flyaa/pkg/cache"
+	"github.com/igolaizola/flyaa/pkg/store"
 )
 
 type Config struct {
-	Debug       bool
-	Proxy       string
-	BaseURL     string
-	Origin      string
-	Destination string
-	Date        string
-	Passengers  int
-	CabinClass  string
-}
-
-type response struct {
-	SearchMetadata struct {
-		Origin      string `json:"origin"`
-		Destination string `json:"destination"`
-		Date        string `json:"date"`
-		Passengers  int    `json:"passengers"`
-		CabinClass  string `json:"cabin_class"`
-	} `json:"search_metadata"`
-	Flights []aa.Flight `json:"flights"`
+	Debug               bool
+	Proxies             []string
+	ProxyFile           string
+	ProxySelection      string
+	ProxyQuarantine     time.Duration
+	BaseURL             string
+	FreshClient         bool
+	Retry               aa.RetryPolicy
+	RateLimit           float64
+	RateBurst           int
+	MaxInFlight         int
+	MaxFollowUps        int
+	MaxFollowUpRequests int
+	Record              string
+	Replay              string
+	Cache               string
+	CacheDir            string
+	CacheTTL            time.Duration
+	CacheSize           int
+	NoCache             bool
+	Refresh             bool
+	Store               string
+	// Output is the format of the results: auto, json or table. Color
+	// enables the colors of the table: auto, always or never.
+	Output string
+	Color  string
+	SearchParams
 }
 
 func Run(ctx context.Context, cfg *Config) error {
 	// Validate input
-	if cfg.BaseURL == "" {
+	if cfg.BaseURL == "" && cfg.Replay == "" {
 		return fmt.Errorf("base URL is required")
 	}
-	origin := strings.ToUpper(cfg.Origin)
-	if len(origin) != 3 {
-		return fmt.Errorf("origin airport code must be 3 letters")
-	}
-	destination := strings.ToUpper(cfg.Destination)
-	if len(destination) != 3 {
-		return fmt.Errorf("destination airport code must be 3 letters")
+	if err := validateOutput(cfg.Output, cfg.Color); err != nil {
+		return err
 	}
-	date := cfg.Date
-	if _, err := time.Parse("2006-01-02", date); err != nil {
-		return fmt.Errorf("flight date must be in YYYY-MM-DD format: %w", err)
+
+	// Create service client
+	svc, err := newClient(cfg)
+	if err != nil {
+		return err
 	}
-	passengers := cfg.Passengers
-	if passengers <= 0 {
-		passengers = 1
+	defer logProxyStats(svc)
+	st, err := openStore(cfg)
+	if err != nil {
+		return err
 	}
+	defer closeStore(st)
 
-	// Map cabin class
-	cabinClass := strings.ToLower(cfg.CabinClass)
-	var productType string
-	switch cabinClass {
-	case "economy":
-		productType = "BASIC_ECONOMY"
-	case "main":
-		productType = "COACH"
-	case "main-plus":
-		productType = "COACH_FLEXIBLE"
-	default:
-		return fmt.Errorf("unsupported cabin class %q, supported values are: economy, main, main-plus", cabinClass)
+	// Search flights
+	params := cfg.SearchParams
+	params.Client = svc
+	params.Store = st
+	params.Source = "search"
+	resp, err := Search(ctx, params)
+	if err != nil {
+		return err
 	}
 
-	// Create service client
+	// Print response
+	return writeResult(os.Stdout, resp, cfg.Output, cfg.Color)
+}
+
+// newClient creates the AA service client from the config.
+func newClient(cfg *Config) (*aa.Client, error) {
+	proxies := cfg.Proxies
+	if cfg.ProxyFile != "" {
+		ps, err := readProxyFile(cfg.ProxyFile)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, ps...)
+	}
+	c, err := newCache(cfg)
+	if err != nil {
+		return nil, err
+	}
 	svc, err := aa.New(&aa.Config{
-		Debug:   cfg.Debug,
-		Proxy:   cfg.Proxy,
-		BaseURL: cfg.BaseURL,
+		Debug:               cfg.Debug,
+		Proxies:             proxies,
+		ProxySelection:      cfg.ProxySelection,
+		ProxyQuarantine:     cfg.ProxyQuarantine,
+		BaseURL:             cfg.BaseURL,
+		FreshClient:         cfg.FreshClient,
+		Retry:               cfg.Retry,
+		RateLimit:           cfg.RateLimit,
+		RateBurst:           cfg.RateBurst,
+		MaxInFlight:         cfg.MaxInFlight,
+		MaxFollowUps:        cfg.MaxFollowUps,
+		MaxFollowUpRequests: cfg.MaxFollowUpRequests,
+		Record:              cfg.Record,
+		Replay:              cfg.Replay,
+		Cache:               c,
+		CacheTTL:            cfg.CacheTTL,
+		CacheRefresh:        cfg.Refresh,
 	})
 	if err != nil {
-		return fmt.Errorf("couldn't create aa client: %w", err)
+		return nil, fmt.Errorf("couldn't create aa client: %w", err)
 	}
+	return svc, nil
+}
 
-	// Search flightsPrice
-	var flightsPrice, flightPoints []aa.Flight
+// openStore opens the store of the searches from the config. It returns nil
+// if no store is configured.
+func openStore(cfg *Config) (store.Store, error) {
+	if cfg.Store == "" {
+		return nil, nil
+	}
+	st, err := store.Open(cfg.Store)
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
 
-	// Run both searches concurrently
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(2)
-	g.Go(func() error {
-		// Regular search
-		fs, err := svc.Search(ctx, origin, destination, date, passengers, productType, false)
-		if err != nil {
-			return fmt.Errorf("search failed: %w", err)
-		}
-		flightsPrice = fs
-		return nil
-	})
-	g.Go(func() error {
-		// Points search
-		fs, err := svc.Search(ctx, origin, destination, date, passengers, productType, true)
-		if err != nil {
-			return fmt.Errorf("search points failed: %w", err)
-		}
-		flightPoints = fs
-		return nil
-	})
-	if err := g.Wait(); err != nil {
-		return err
+// closeStore closes the store if there is one.
+func closeStore(st store.Store) {
+	if st == nil {
+		return
 	}
+	if err := st.Close(); err != nil {
+		log.Printf("couldn't close store: %v\n", err)
+	}
+}
 
-	// Combine results
-	var flights []aa.Flight
-	lookup := make(map[string]aa.Flight)
-	for _, f := range flightPoints {
-		lookup[f.ID()] = f
-	}
-	for i := range flightsPrice {
-		flight := flightsPrice[i]
-		fp, ok := lookup[flight.ID()]
-		if !ok {
-			continue
+// newCache creates the cache of search results from the config. It returns
+// nil if the cache is disabled.
+func newCache(cfg *Config) (cache.Cache, error) {
+	if cfg.NoCache {
+		return nil, nil
+	}
+	switch cfg.Cache {
+	case "", "memory":
+		return cache.NewMemory(cfg.CacheSize), nil
+	case "disk":
+		dir := cfg.CacheDir
+		if dir == "" {
+			userDir, err := os.UserCacheDir()
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get user cache directory: %w", err)
+			}
+			dir = filepath.Join(userDir, "flyaa")
 		}
-		if fp.PointsRequired == 0 {
+		return cache.NewDisk(dir)
+	default:
+		return nil, fmt.Errorf("unsupported cache %q, supported values are: memory, disk", cfg.Cache)
+	}
+}
+
+// readProxyFile reads a file with a proxy URL per line, ignoring empty lines
+// and lines starting with #.
+func readProxyFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read proxy file: %w", err)
+	}
+	var proxies []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		flight.PointsRequired = fp.PointsRequired
-		flight.TaxesFeesUSD = fp.TaxesFeesUSD
-		flight.CPP = (flight.CashPriceUSD - fp.TaxesFeesUSD) / float64(fp.PointsRequired) * 100.0
-		flight.CPP = round(flight.CPP, 2)
-		flights = append(flights, flight)
+		proxies = append(proxies, line)
 	}
+	return proxies, nil
+}
 
-	// Print response
-	var resp response
-	resp.SearchMetadata.Origin = origin
-	resp.SearchMetadata.Destination = destination
-	resp.SearchMetadata.Date = date
-	resp.SearchMetadata.Passengers = passengers
-	resp.SearchMetadata.CabinClass = cabinClass
-	resp.Flights = flights
-
-	data, err := json.MarshalIndent(resp, "", "  ")
-	if err != nil {
-		return fmt.Errorf("couldn't marshal response: %w", err)
+// logProxyStats logs the successful and failed requests of each proxy when
+// more than one proxy is used.
+func logProxyStats(svc *aa.Client) {
+	stats := svc.ProxyStats()
+	if len(stats) < 2 {
+		return
+	}
+	for _, s := range stats {
+		log.Printf("proxy %s: %d successes, %d failures, %d quarantines\n", s.Proxy, s.Successes, s.Failures, s.Quarantines)
 	}
-	fmt.Println(string(data))
-	return nil
 }
 
 func round(x float64, prec int) float64 {
@@ -0,0 +1,165 @@
+package flyaa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/flyaa/pkg/store"
+)
+
+type HistoryConfig struct {
+	Store       string
+	Origin      string
+	Destination string
+	Date        string
+	FlightID    string
+	CabinClass  string
+	// Since and Until limit the time of the searches, either as dates
+	// (YYYY-MM-DD) or RFC3339 timestamps.
+	Since string
+	Until string
+	Limit int
+}
+
+type historyResponse struct {
+	Observations int             `json:"observations"`
+	Flights      []flightHistory `json:"flights"`
+}
+
+// flightHistory is the price trend of a flight of a route and date.
+type flightHistory struct {
+	FlightID     string              `json:"flight_id"`
+	Origin       string              `json:"origin"`
+	Destination  string              `json:"destination"`
+	Date         string              `json:"date"`
+	ReturnDate   string              `json:"return_date,omitempty"`
+	Passengers   int                 `json:"passengers"`
+	CabinClass   string              `json:"cabin_class"`
+	FirstSeen    time.Time           `json:"first_seen"`
+	LastSeen     time.Time           `json:"last_seen"`
+	Observations int                 `json:"observations"`
+	Cash         *priceTrend         `json:"cash_price_usd"`
+	Points       *priceTrend         `json:"points_required"`
+	CPP          *priceTrend         `json:"cpp"`
+	History      []store.Observation `json:"history"`
+}
+
+// priceTrend summarizes the values of a price over time.
+type priceTrend struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	First  float64 `json:"first"`
+	Latest float64 `json:"latest"`
+}
+
+func (t *priceTrend) add(v float64) *priceTrend {
+	if t == nil {
+		return &priceTrend{Min: v, Max: v, First: v, Latest: v}
+	}
+	t.Min = min(t.Min, v)
+	t.Max = max(t.Max, v)
+	t.Latest = v
+	return t
+}
+
+// History prints the stored price history of the flights matching the
+// config, grouped by flight.
+func History(ctx context.Context, cfg *HistoryConfig) error {
+	// Validate input
+	if cfg.Store == "" {
+		return fmt.Errorf("store is required")
+	}
+	q := &store.HistoryQuery{
+		Origin:      cfg.Origin,
+		Destination: cfg.Destination,
+		Date:        cfg.Date,
+		FlightID:    strings.ToUpper(cfg.FlightID),
+		CabinClass:  cfg.CabinClass,
+		Limit:       cfg.Limit,
+	}
+	var err error
+	if q.Since, err = parseHistoryTime(cfg.Since, false); err != nil {
+		return fmt.Errorf("invalid since: %w", err)
+	}
+	if q.Until, err = parseHistoryTime(cfg.Until, true); err != nil {
+		return fmt.Errorf("invalid until: %w", err)
+	}
+
+	// Query the store
+	st, err := store.Open(cfg.Store)
+	if err != nil {
+		return err
+	}
+	defer closeStore(st)
+	obs, err := st.History(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	// Group the observations by itinerary and party size
+	resp := historyResponse{Observations: len(obs), Flights: []flightHistory{}}
+	index := map[string]int{}
+	for _, o := range obs {
+		key := strings.Join([]string{o.FlightID, o.Origin, o.Destination, o.Date, o.ReturnDate, strconv.Itoa(o.Passengers), o.CabinClass}, "|")
+		i, ok := index[key]
+		if !ok {
+			i = len(resp.Flights)
+			index[key] = i
+			resp.Flights = append(resp.Flights, flightHistory{
+				FlightID:    o.FlightID,
+				Origin:      o.Origin,
+				Destination: o.Destination,
+				Date:        o.Date,
+				ReturnDate:  o.ReturnDate,
+				Passengers:  o.Passengers,
+				CabinClass:  o.CabinClass,
+				FirstSeen:   o.Time,
+			})
+		}
+		h := &resp.Flights[i]
+		h.LastSeen = o.Time
+		h.Observations++
+		if o.CashPriceUSD != nil {
+			h.Cash = h.Cash.add(*o.CashPriceUSD)
+		}
+		if o.PointsRequired != nil {
+			h.Points = h.Points.add(float64(*o.PointsRequired))
+		}
+		if o.CPP != nil {
+			h.CPP = h.CPP.add(*o.CPP)
+		}
+		h.History = append(h.History, o)
+	}
+
+	// Print response
+	data, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't marshal response: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// parseHistoryTime parses a date or a RFC3339 timestamp. Dates are the start
+// of the day, or its end if endOfDay is true, so they include the whole day.
+// It returns the zero time if the value is empty.
+func parseHistoryTime(v string, endOfDay bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be in YYYY-MM-DD or RFC3339 format: %w", err)
+	}
+	return t, nil
+}
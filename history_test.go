@@ -0,0 +1,33 @@
+package flyaa
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHistoryTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		endOfDay bool
+		want     time.Time
+		wantErr  bool
+	}{
+		{name: "empty"},
+		{name: "date", in: "2025-12-15", want: time.Date(2025, 12, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "date until end of day", in: "2025-12-15", endOfDay: true, want: time.Date(2025, 12, 15, 23, 59, 59, 999999999, time.UTC)},
+		{name: "timestamp", in: "2025-12-15T10:30:00Z", endOfDay: true, want: time.Date(2025, 12, 15, 10, 30, 0, 0, time.UTC)},
+		{name: "invalid", in: "15/12/2025", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHistoryTime(tt.in, tt.endOfDay)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package flyaa
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+)
+
+// Output formats of the search results.
+const (
+	outputAuto   = "auto"
+	outputJSON   = "json"
+	outputTable  = "table"
+	outputCSV    = "csv"
+	outputNDJSON = "ndjson"
+)
+
+// outputs lists the supported output formats.
+var outputs = []string{outputAuto, outputJSON, outputTable, outputCSV, outputNDJSON}
+
+// Color modes of the table output.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// ANSI escape codes used to highlight the table output.
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// writeResult writes the result in the output format. The auto format uses a
+// table when writing to a terminal and JSON otherwise.
+func writeResult(w *os.File, r *Result, output, color string) error {
+	tty := isTerminal(w)
+	switch output {
+	case "", outputAuto:
+		output = outputJSON
+		if tty {
+			output = outputTable
+		}
+	}
+	switch output {
+	case outputJSON:
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return fmt.Errorf("couldn't marshal response: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case outputTable:
+		useColor, err := colorEnabled(color, tty)
+		if err != nil {
+			return err
+		}
+		return writeTable(w, r, useColor)
+	case outputCSV:
+		return writeCSV(w, r)
+	case outputNDJSON:
+		return writeNDJSON(w, r)
+	default:
+		return fmt.Errorf("unsupported output %q, supported values are: %s", output, strings.Join(outputs, ", "))
+	}
+}
+
+// validateOutput checks the output format and color mode before searching.
+func validateOutput(output, color string) error {
+	if output != "" && !slices.Contains(outputs, output) {
+		return fmt.Errorf("unsupported output %q, supported values are: %s", output, strings.Join(outputs, ", "))
+	}
+	_, err := colorEnabled(color, false)
+	return err
+}
+
+// isTerminal returns whether the file is a terminal instead of a pipe or a
+// regular file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorEnabled returns whether the output must be colored. The auto mode
+// colors terminals unless the NO_COLOR environment variable is set.
+func colorEnabled(color string, tty bool) (bool, error) {
+	switch color {
+	case "", colorAuto:
+		return tty && os.Getenv("NO_COLOR") == "", nil
+	case colorAlways:
+		return true, nil
+	case colorNever:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported color %q, supported values are: auto, always, never", color)
+	}
+}
+
+// tableRow is a row of the table output. Highlighted rows are the ones with
+// the best CPP.
+type tableRow struct {
+	cells     []string
+	nonstop   bool
+	highlight bool
+}
+
+var tableHeader = []string{"FLIGHT", "ROUTE", "DEPART", "ARRIVE", "DURATION", "STOPS", "CASH", "POINTS", "TAXES", "CPP"}
+
+// stopsColumn is the index of the stops column.
+const stopsColumn = 5
+
+// writeTable writes the flights as an aligned table. Round-trip and
+// multi-city flights have a row per leg, with the prices in the first one.
+func writeTable(w io.Writer, r *Result, color bool) error {
+	meta := r.SearchMetadata
+	route := fmt.Sprintf("%s → %s %s", meta.Origin, meta.Destination, meta.Date)
+	if meta.ReturnDate != "" {
+		route += " ↔ " + meta.ReturnDate
+	}
+	if len(meta.Legs) > 0 {
+		var legs []string
+		for _, l := range meta.Legs {
+			legs = append(legs, fmt.Sprintf("%s-%s %s", l.Origin, l.Destination, l.Date))
+		}
+		route = strings.Join(legs, ", ")
+	}
+	passengers := "passengers"
+	if meta.Passengers == 1 {
+		passengers = "passenger"
+	}
+	title := fmt.Sprintf("%s · %d %s · %s · %d flights", route, meta.Passengers, passengers, meta.CabinClass, len(r.Flights))
+	if color {
+		title = ansiBold + title + ansiReset
+	}
+	if _, err := fmt.Fprintln(w, title); err != nil {
+		return err
+	}
+	if len(r.Flights) == 0 {
+		return nil
+	}
+
+	// Find the best CPP
+	var best *float64
+	for _, f := range r.Flights {
+		if f.CPP != nil && (best == nil || *f.CPP > *best) {
+			best = f.CPP
+		}
+	}
+
+	// Build rows
+	rows := []tableRow{{cells: tableHeader}}
+	for _, f := range r.Flights {
+		highlight := best != nil && f.CPP != nil && *f.CPP == *best
+		legs := f.Legs
+		if len(legs) == 0 {
+			legs = []aa.FlightLeg{{IsNonstop: f.IsNonstop, Segments: f.Segments, TotalDuration: f.TotalDuration}}
+		}
+		for i, leg := range legs {
+			row := tableRow{
+				cells:     legCells(leg),
+				nonstop:   leg.IsNonstop,
+				highlight: highlight,
+			}
+			if i == 0 {
+				row.cells = append(row.cells, formatCash(f.CashPriceUSD), formatPoints(f.PointsRequired), formatCash(f.TaxesFeesUSD), formatCPP(f.CPP))
+			} else {
+				row.cells = append(row.cells, "", "", "", "")
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	// Calculate column widths
+	widths := make([]int, len(tableHeader))
+	for _, row := range rows {
+		for i, c := range row.cells {
+			widths[i] = max(widths[i], len([]rune(c)))
+		}
+	}
+
+	// Write rows, numbers aligned to the right
+	for n, row := range rows {
+		var sb strings.Builder
+		for i, c := range row.cells {
+			pad := strings.Repeat(" ", widths[i]-len([]rune(c)))
+			cell := c + pad
+			if i >= stopsColumn+1 {
+				cell = pad + c
+			}
+			if color && n > 0 && i == stopsColumn && row.nonstop && !row.highlight {
+				cell = ansiCyan + cell + ansiReset
+			}
+			if i > 0 {
+				sb.WriteString("  ")
+			}
+			sb.WriteString(cell)
+		}
+		line := strings.TrimRight(sb.String(), " ")
+		if color {
+			switch {
+			case n == 0:
+				line = ansiBold + line + ansiReset
+			case row.highlight:
+				line = ansiBold + ansiGreen + line + ansiReset
+			}
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legCells returns the flight numbers, route, times, duration and stops of a
+// leg.
+func legCells(leg aa.FlightLeg) []string {
+	var numbers []string
+	for _, s := range leg.Segments {
+		numbers = append(numbers, s.FlightNumber)
+	}
+	var route, depart, arrive string
+	if n := len(leg.Segments); n > 0 {
+		first, last := leg.Segments[0], leg.Segments[n-1]
+		airports := []string{first.Origin}
+		for _, s := range leg.Segments {
+			airports = append(airports, s.Destination)
+		}
+		route = strings.Join(airports, "-")
+		depart = first.DepartureTime
+		arrive = last.ArrivalTime
+		if last.ArrivalDayOffset > 0 {
+			arrive += fmt.Sprintf("+%d", last.ArrivalDayOffset)
+		}
+	}
+	stops := "nonstop"
+	if !leg.IsNonstop {
+		stops = strconv.Itoa(max(len(leg.Segments)-1, 1))
+	}
+	return []string{strings.Join(numbers, " "), route, depart, arrive, leg.TotalDuration, stops}
+}
+
+func formatCash(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("$%.2f", *v)
+}
+
+func formatPoints(v *int) string {
+	if v == nil {
+		return "-"
+	}
+	// Add thousands separators
+	s := strconv.Itoa(*v)
+	var sb strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+func formatCPP(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", *v)
+}
@@ -1,16 +1,20 @@
 package aa
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"math"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
 	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
 )
 
 type searchRequest struct {
@@ -98,36 +102,186 @@ type responsePricingDetail struct {
 		Amount   float64 `json:"amount"`
 		Currency string  `json:"currency"`
 	} `json:"allPassengerTaxesAndFees"`
-	ProductType string `json:"productType"`
-	SolutionID  string `json:"solutionID"`
+	ProductType      string                     `json:"productType"`
+	SolutionID       string                     `json:"solutionID"`
+	PassengerPricing []responsePassengerPricing `json:"passengerPricing"`
+}
+
+type responsePassengerPricing struct {
+	PassengerType            string `json:"passengerType"`
+	Count                    int    `json:"count"`
+	PerPassengerPrice        string `json:"perPassengerPrice"`
+	AllPassengerTaxesAndFees struct {
+		Amount   float64 `json:"amount"`
+		Currency string  `json:"currency"`
+	} `json:"allPassengerTaxesAndFees"`
 }
 
 type Flight struct {
 	IsNonstop      bool            `json:"is_nonstop"`
-	Segments       []FlightSegment `json:"segments"`
+	Segments       []FlightSegment `json:"segments,omitempty"`
+	Legs           []FlightLeg     `json:"legs,omitempty"`
 	TotalDuration  string          `json:"total_duration"`
-	PointsRequired int             `json:"points_required"`
-	CashPriceUSD   float64         `json:"cash_price_usd"`
-	TaxesFeesUSD   float64         `json:"taxes_fees_usd"`
-	CPP            float64         `json:"cpp"`
+	PointsRequired *int            `json:"points_required"`
+	CashPriceUSD   *float64        `json:"cash_price_usd"`
+	TaxesFeesUSD   *float64        `json:"taxes_fees_usd"`
+	CPP            *float64        `json:"cpp"`
+	NoCPPReason    string          `json:"no_cpp_reason,omitempty"`
+	Products       []Product       `json:"products,omitempty"`
+
+	TotalCashPriceUSD   *float64         `json:"total_cash_price_usd"`
+	TotalPointsRequired *int             `json:"total_points_required"`
+	TotalTaxesFeesUSD   *float64         `json:"total_taxes_fees_usd"`
+	PassengerPricing    []PassengerPrice `json:"passenger_pricing,omitempty"`
+}
+
+// PassengerPrice is the pricing of a single passenger of a passenger type.
+type PassengerPrice struct {
+	Type           string   `json:"type"`
+	Count          int      `json:"count"`
+	CashPriceUSD   *float64 `json:"cash_price_usd"`
+	PointsRequired *int     `json:"points_required"`
+	TaxesFeesUSD   *float64 `json:"taxes_fees_usd"`
+}
+
+// Product is the pricing of one of the fare products of a flight.
+type Product struct {
+	ProductType     string   `json:"product_type"`
+	CashPriceUSD    *float64 `json:"cash_price_usd"`
+	CashSolutionID  string   `json:"cash_solution_id,omitempty"`
+	PointsRequired  *int     `json:"points_required"`
+	TaxesFeesUSD    *float64 `json:"taxes_fees_usd"`
+	AwardSolutionID string   `json:"award_solution_id,omitempty"`
+	CPP             *float64 `json:"cpp"`
+	NoCPPReason     string   `json:"no_cpp_reason,omitempty"`
+}
+
+// FlightLeg is one of the directional legs of a round-trip or multi-city
+// flight.
+type FlightLeg struct {
+	Origin        string          `json:"origin"`
+	Destination   string          `json:"destination"`
+	Date          string          `json:"date"`
+	IsNonstop     bool            `json:"is_nonstop"`
+	Segments      []FlightSegment `json:"segments"`
+	TotalDuration string          `json:"total_duration"`
 }
 
 type FlightSegment struct {
 	FlightNumber  string `json:"flight_number"`
 	DepartureTime string `json:"departure_time"`
 	ArrivalTime   string `json:"arrival_time"`
+
+	Origin          string `json:"origin"`
+	OriginCity      string `json:"origin_city"`
+	Destination     string `json:"destination"`
+	DestinationCity string `json:"destination_city"`
+	CarrierCode     string `json:"carrier_code"`
+	CarrierName     string `json:"carrier_name"`
+	// DepartureDateTime and ArrivalDateTime are local times in RFC3339 format,
+	// including the UTC offset of the airport.
+	DepartureDateTime string `json:"departure_datetime"`
+	ArrivalDateTime   string `json:"arrival_datetime"`
+	// DepartureDayOffset and ArrivalDayOffset are the number of days after the
+	// departure date of the first segment of the leg (e.g. 1 for "+1").
+	DepartureDayOffset int `json:"departure_day_offset,omitempty"`
+	ArrivalDayOffset   int `json:"arrival_day_offset,omitempty"`
+	// LayoverMinutes is the connection time between the previous segment and
+	// this one.
+	LayoverMinutes  int    `json:"layover_minutes,omitempty"`
+	LayoverDuration string `json:"layover_duration,omitempty"`
 }
 
 // ID generates a unique ID for the flight based on its segments' flight numbers.
+// Legs of round-trip and multi-city flights are separated by a dash.
 func (f *Flight) ID() string {
+	if len(f.Legs) > 0 {
+		var ids []string
+		for _, leg := range f.Legs {
+			ids = append(ids, segmentsID(leg.Segments))
+		}
+		return strings.Join(ids, "-")
+	}
+	return segmentsID(f.Segments)
+}
+
+func segmentsID(segs []FlightSegment) string {
 	var numbers []string
-	for _, seg := range f.Segments {
+	for _, seg := range segs {
 		numbers = append(numbers, seg.FlightNumber)
 	}
 	return strings.Join(numbers, "_")
 }
 
-func (c *Client) Search(ctx context.Context, origin, destination, date string, passengers int, productType string, redeemPoints bool) ([]Flight, error) {
+// Passenger types supported by the search.
+const (
+	PassengerAdult      = "adult"
+	PassengerChild      = "child"
+	PassengerInfantLap  = "infantInLap"
+	PassengerInfantSeat = "infantInSeat"
+	PassengerSenior     = "senior"
+)
+
+// Passenger is the number of passengers of a passenger type.
+type Passenger struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// Slice is a single origin and destination pair to search for.
+type Slice struct {
+	Origin      string `json:"origin"`
+	Destination string `json:"destination"`
+	Date        string `json:"date"`
+}
+
+// Query contains the parameters of a search.
+// A single slice searches one-way flights, two slices where the second one
+// returns to the origin search round-trip flights and any other list of slices
+// searches multi-city flights.
+type Query struct {
+	Slices []Slice
+	// Passengers lists the number of passengers of each type. Per passenger
+	// prices are the average of all of them.
+	Passengers  []Passenger
+	ProductType string
+	// Cabin restricts the search to a cabin (e.g. "BUSINESS"). If set, points
+	// searches use the pricing of the product type instead of the cheapest one.
+	Cabin        string
+	RedeemPoints bool
+	// AllProducts keeps the pricing of every fare product of the flights
+	// instead of discarding the flights without the selected product type.
+	AllProducts bool
+}
+
+// followUpConcurrency is the maximum number of concurrent requests used to
+// search the following slices of a round-trip or multi-city search.
+const followUpConcurrency = 4
+
+// defaultMaxFollowUps is the default number of options of each slice whose
+// following slices are searched.
+const defaultMaxFollowUps = 10
+
+// defaultMaxFollowUpRequests is the default number of follow-up requests of
+// a search, across all its slices.
+const defaultMaxFollowUpRequests = 100
+
+// search runs a search without using the cache.
+func (c *Client) search(ctx context.Context, q *Query) ([]Flight, error) {
+	// Validate input
+	if len(q.Slices) == 0 {
+		return nil, fmt.Errorf("aa: at least one slice is required")
+	}
+	tripType := "multiCity"
+	switch {
+	case len(q.Slices) == 1:
+		tripType = "oneWay"
+	case len(q.Slices) == 2 &&
+		q.Slices[0].Origin == q.Slices[1].Destination &&
+		q.Slices[0].Destination == q.Slices[1].Origin:
+		tripType = "roundTrip"
+	}
+
 	// Generate random IDs
 	transactionID := uuid.New().String()
 	bookingSessionID := uuid.New().String()
@@ -135,102 +289,470 @@ func (c *Client) Search(ctx context.Context, origin, destination, date string, p
 	// Create request
 	var req searchRequest
 	req.Metadata.SelectedProducts = []string{}
-	req.Metadata.TripType = "oneWay"
-	req.Passengers = []searchPassenger{
-		{Type: "adult", Count: passengers},
+	req.Metadata.TripType = tripType
+	for _, p := range q.Passengers {
+		if p.Count <= 0 {
+			continue
+		}
+		req.Passengers = append(req.Passengers, searchPassenger{Type: p.Type, Count: p.Count})
+	}
+	if len(req.Passengers) == 0 {
+		return nil, fmt.Errorf("aa: at least one passenger is required")
 	}
 	req.RequestHeader.ClientID = "mobile"
 	req.RequestHeader.TransactionID = transactionID
 	req.RequestHeader.BookingSessionID = bookingSessionID
-	req.Slices = []searchSlice{
-		{
+	for _, s := range q.Slices {
+		req.Slices = append(req.Slices, searchSlice{
 			AllCarriers:           true,
-			Cabin:                 "",
-			DepartureDate:         date,
-			Destination:           destination,
+			Cabin:                 q.Cabin,
+			DepartureDate:         s.Date,
+			Destination:           s.Destination,
 			IncludeNearbyAirports: false,
-			Origin:                origin,
-		},
+			Origin:                s.Origin,
+		})
 	}
 	req.TripOptions.FareType = "Lowest"
 	req.TripOptions.Locale = "en_US"
 	req.TripOptions.SearchType = "revenue"
-	if redeemPoints {
+	if q.RedeemPoints {
 		req.TripOptions.SearchType = "award"
 	}
+	return c.searchSlice(ctx, q, req, nil, newFollowUpBudget(c.followUpRequests))
+}
+
+// sliceOption is a priced option for a single slice of the search.
+type sliceOption struct {
+	leg              FlightLeg
+	minutes          int
+	cashPrice        *float64
+	pointsRequired   *int
+	taxesFees        *float64
+	totalCashPrice   *float64
+	totalPoints      *int
+	totalTaxesFees   *float64
+	passengerPricing []PassengerPrice
+	solutionID       string
+	products         []Product
+}
 
+// searchSlice searches the options of the slice at the query params slice
+// index. If there are more slices left, the selected solution of each option is
+// used to search the following slice and the returned flights combine all of
+// them. The budget limits the follow-up requests of the whole search.
+func (c *Client) searchSlice(ctx context.Context, q *Query, req searchRequest, prev []sliceOption, budget *followUpBudget) ([]Flight, error) {
 	// Do request
 	var resp searchResponse
 	if _, err := c.do(ctx, "POST", "search/itinerary/v2.0", &req, &resp); err != nil {
 		return nil, err
 	}
+	if apiErr := parseAPIError(resp.Error); apiErr != nil {
+		return nil, apiErr
+	}
 
 	// Parse response
-	var flights []Flight
+	idx := req.QueryParams.SliceIndex
+	var opts []sliceOption
 	for _, slice := range resp.Slices {
-		var segs []FlightSegment
-		for _, sg := range slice.Segments {
-			// Build flight number
-			flightNumber := fmt.Sprintf("%s%s", sg.Flight.CarrierCode, sg.Flight.FlightNumber)
+		opt, ok, err := parseSliceOption(slice, q)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		opt.leg.Origin = q.Slices[idx].Origin
+		opt.leg.Destination = q.Slices[idx].Destination
+		opt.leg.Date = q.Slices[idx].Date
+		opts = append(opts, opt)
+	}
 
-			// Parse times
-			departureTime, err := parseTime(sg.DepartureDateTime)
-			if err != nil {
-				return nil, fmt.Errorf("couldn't parse departure time: %w", err)
+	// Return flights if this is the last slice
+	if idx == len(q.Slices)-1 {
+		var flights []Flight
+		for _, opt := range opts {
+			flights = append(flights, newFlight(append(slices.Clone(prev), opt)))
+		}
+		return flights, nil
+	}
+
+	// Search the next slice for the cheapest options
+	opts = c.followUpOptions(opts)
+	if len(opts) > 0 {
+		// Each followed option needs at least a request per following slice
+		// to reach the last one. The requests of the first option were
+		// already reserved by the previous slice.
+		cost := len(q.Slices) - 1 - idx
+		reserved := 0
+		if idx > 0 {
+			reserved = 1
+		}
+		opts = opts[:reserved+budget.reserve(len(opts)-reserved, cost)]
+	}
+	results := make([][]Flight, len(opts))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(followUpConcurrency)
+	for i, opt := range opts {
+		next := req
+		next.QueryParams.SliceIndex = idx + 1
+		next.QueryParams.SessionID = resp.ResponseMetadata.SessionID
+		next.QueryParams.SolutionSet = resp.ResponseMetadata.SolutionSet
+		next.QueryParams.SolutionID = opt.solutionID
+		g.Go(func() error {
+			fs, err := c.searchSlice(ctx, q, next, append(slices.Clone(prev), opt), budget)
+			if errors.Is(err, ErrNoAvailability) {
+				// No options for the next slice with this solution
+				return nil
 			}
-			arrivalTime, err := parseTime(sg.ArrivalDateTime)
 			if err != nil {
-				return nil, fmt.Errorf("couldn't parse arrival time: %w", err)
+				return fmt.Errorf("couldn't search slice %d for solution %s: %w", idx+1, opt.solutionID, err)
 			}
-			segs = append(segs, FlightSegment{
-				FlightNumber:  flightNumber,
-				DepartureTime: departureTime,
-				ArrivalTime:   arrivalTime,
-			})
+			results[i] = fs
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	var flights []Flight
+	for _, fs := range results {
+		flights = append(flights, fs...)
+	}
+	return flights, nil
+}
+
+// followUpOptions returns the options whose following slices are searched:
+// the cheapest ones with a solution, up to the maximum number of follow-ups.
+// The requests of a search grow exponentially with the number of slices, so
+// following every option could send thousands of them.
+func (c *Client) followUpOptions(opts []sliceOption) []sliceOption {
+	var follow []sliceOption
+	for _, opt := range opts {
+		if opt.solutionID != "" {
+			follow = append(follow, opt)
 		}
+	}
+	if c.followUps < 0 || len(follow) <= c.followUps {
+		return follow
+	}
+	slices.SortStableFunc(follow, func(a, b sliceOption) int {
+		return cmp.Compare(a.price(), b.price())
+	})
+	return follow[:c.followUps]
+}
 
-		// Find pricing
-		var cashPrice float64
-		var pointsRequired int
-		var taxesFees float64
-		if redeemPoints {
-			// For points searches, use always the cheapest price
-			var err error
-			pointsRequired, err = parseAbbrevInt(slice.CheapestPrice.PerPassengerPrice)
-			if err != nil {
-				return nil, fmt.Errorf("couldn't parse points price %q: %w", slice.CheapestPrice.PerPassengerPrice, err)
-			}
-			taxesFees = slice.CheapestPrice.AllPassengerTaxesAndFees.Amount / float64(passengers)
-		} else {
-			// For cash searches, find the matching product type
+// followUpBudget is the number of follow-up requests left of a search, shared
+// by all its slices.
+type followUpBudget struct {
+	lck  sync.Mutex
+	left int
+}
+
+// newFollowUpBudget creates a budget of n requests. Negative values don't
+// limit the requests.
+func newFollowUpBudget(n int) *followUpBudget {
+	if n < 0 {
+		return nil
+	}
+	return &followUpBudget{left: n}
+}
+
+// reserve takes up to n options that cost the given requests each from the
+// budget and returns how many were taken.
+func (b *followUpBudget) reserve(n, cost int) int {
+	if b == nil || cost <= 0 {
+		return n
+	}
+	b.lck.Lock()
+	defer b.lck.Unlock()
+	n = min(n, b.left/cost)
+	b.left -= n * cost
+	return n
+}
+
+// price returns the cash price or the points of the option, or infinity if
+// it doesn't have any.
+func (o sliceOption) price() float64 {
+	switch {
+	case o.cashPrice != nil:
+		return *o.cashPrice
+	case o.pointsRequired != nil:
+		return float64(*o.pointsRequired)
+	default:
+		return math.Inf(1)
+	}
+}
+
+// parseSliceOption parses the segments and the pricing of a response slice.
+// It returns false if the slice doesn't have a price for the query.
+func parseSliceOption(slice responseSlice, q *Query) (sliceOption, bool, error) {
+	passengers := countPassengers(q.Passengers, false)
+	var segs []FlightSegment
+	var firstDeparture, prevArrival time.Time
+	for i, sg := range slice.Segments {
+		// Build flight number
+		flightNumber := fmt.Sprintf("%s%s", sg.Flight.CarrierCode, sg.Flight.FlightNumber)
+
+		// Parse times
+		departureTime, err := parseTime(sg.DepartureDateTime)
+		if err != nil {
+			return sliceOption{}, false, fmt.Errorf("couldn't parse departure time: %w", err)
+		}
+		arrivalTime, err := parseTime(sg.ArrivalDateTime)
+		if err != nil {
+			return sliceOption{}, false, fmt.Errorf("couldn't parse arrival time: %w", err)
+		}
+		if i == 0 {
+			firstDeparture = departureTime
+		}
+		seg := FlightSegment{
+			FlightNumber:       flightNumber,
+			DepartureTime:      departureTime.Format("15:04"),
+			ArrivalTime:        arrivalTime.Format("15:04"),
+			Origin:             sg.Origin.Code,
+			OriginCity:         cityName(sg.Origin),
+			Destination:        sg.Destination.Code,
+			DestinationCity:    cityName(sg.Destination),
+			CarrierCode:        sg.Flight.CarrierCode,
+			CarrierName:        sg.Flight.CarrierName,
+			DepartureDateTime:  departureTime.Format(time.RFC3339),
+			ArrivalDateTime:    arrivalTime.Format(time.RFC3339),
+			DepartureDayOffset: dayOffset(firstDeparture, departureTime),
+			ArrivalDayOffset:   dayOffset(firstDeparture, arrivalTime),
+		}
+
+		// Calculate layover from the previous segment
+		if i > 0 {
+			seg.LayoverMinutes = int(departureTime.Sub(prevArrival).Minutes())
+			seg.LayoverDuration = formatDuration(seg.LayoverMinutes)
+		}
+		prevArrival = arrivalTime
+		segs = append(segs, seg)
+	}
+
+	// Find pricing
+	opt := sliceOption{
+		leg: FlightLeg{
+			IsNonstop:     slice.Stops == 0,
+			Segments:      segs,
+			TotalDuration: formatDuration(slice.DurationInMinutes),
+		},
+		minutes: slice.DurationInMinutes,
+	}
+	if q.RedeemPoints {
+		// For points searches, use the cheapest price unless a cabin is
+		// selected, in which case the matching product type is used
+		price, ok := slice.CheapestPrice, true
+		if q.Cabin != "" {
+			ok = false
 			for _, pd := range slice.PricingDetail {
-				if pd.ProductType != productType {
-					continue
+				if pd.ProductType == q.ProductType && pd.PerPassengerPrice != "" {
+					price, ok = pd, true
 				}
-				cashPrice = pd.AllPassengerTaxesAndFees.Amount / float64(passengers)
 			}
+		}
+		if ok {
+			seatPoints, err := parseAbbrevInt(price.PerPassengerPrice)
+			if err != nil {
+				return sliceOption{}, false, fmt.Errorf("couldn't parse points price %q: %w", price.PerPassengerPrice, err)
+			}
+			// The points price is per seat, but per passenger prices are
+			// averaged over all of them like the cash price and taxes
+			totalPoints := seatPoints * countPassengers(q.Passengers, true)
+			pointsRequired := averagePoints(totalPoints, passengers)
+			taxesFees := averageAmount(price.AllPassengerTaxesAndFees.Amount, passengers)
+			totalTaxesFees := price.AllPassengerTaxesAndFees.Amount
+			opt.pointsRequired = &pointsRequired
+			opt.taxesFees = &taxesFees
+			opt.totalPoints = &totalPoints
+			opt.totalTaxesFees = &totalTaxesFees
+			opt.solutionID = price.SolutionID
+			opt.passengerPricing, err = parsePassengerPricing(price, q, seatPoints)
+			if err != nil {
+				return sliceOption{}, false, err
+			}
+		}
+	} else {
+		// For cash searches, find the matching product type
+		for _, pd := range slice.PricingDetail {
+			if pd.ProductType != q.ProductType {
+				continue
+			}
+			cashPrice := averageAmount(pd.AllPassengerTaxesAndFees.Amount, passengers)
 			if cashPrice == 0 {
-				// No matching cabin class found
 				continue
 			}
+			totalCashPrice := pd.AllPassengerTaxesAndFees.Amount
+			opt.cashPrice = &cashPrice
+			opt.totalCashPrice = &totalCashPrice
+			opt.solutionID = pd.SolutionID
+			var err error
+			opt.passengerPricing, err = parsePassengerPricing(pd, q, 0)
+			if err != nil {
+				return sliceOption{}, false, err
+			}
 		}
+	}
+	if !q.AllProducts {
+		if opt.cashPrice == nil && opt.pointsRequired == nil {
+			// No matching cabin class found
+			return sliceOption{}, false, nil
+		}
+		return opt, true, nil
+	}
 
-		// Format duration
-		hours := slice.DurationInMinutes / 60
-		minutes := slice.DurationInMinutes % 60
-		duration := fmt.Sprintf("%dh %dm", hours, minutes)
-
-		// Append flight
-		flights = append(flights, Flight{
-			IsNonstop:      slice.Stops == 0,
-			TotalDuration:  duration,
-			Segments:       segs,
-			CashPriceUSD:   cashPrice,
-			PointsRequired: pointsRequired,
-			TaxesFeesUSD:   taxesFees,
-		})
+	// Parse all the products
+	for _, pd := range slice.PricingDetail {
+		p := Product{ProductType: pd.ProductType}
+		amount := averageAmount(pd.AllPassengerTaxesAndFees.Amount, passengers)
+		if q.RedeemPoints {
+			if pd.PerPassengerPrice == "" {
+				continue
+			}
+			seatPoints, err := parseAbbrevInt(pd.PerPassengerPrice)
+			if err != nil {
+				return sliceOption{}, false, fmt.Errorf("couldn't parse %s points price %q: %w", pd.ProductType, pd.PerPassengerPrice, err)
+			}
+			pointsRequired := averagePoints(seatPoints*countPassengers(q.Passengers, true), passengers)
+			p.PointsRequired = &pointsRequired
+			p.TaxesFeesUSD = &amount
+			p.AwardSolutionID = pd.SolutionID
+		} else {
+			if amount == 0 {
+				continue
+			}
+			p.CashPriceUSD = &amount
+			p.CashSolutionID = pd.SolutionID
+		}
+		opt.products = append(opt.products, p)
 	}
-	return flights, nil
+	if len(opt.products) == 0 {
+		// No products found
+		return sliceOption{}, false, nil
+	}
+	return opt, true, nil
+}
+
+// parsePassengerPricing parses the pricing of each passenger type of a pricing
+// detail. If the response doesn't break down the pricing and there is only one
+// passenger type, the per passenger pricing is used for it.
+func parsePassengerPricing(pd responsePricingDetail, q *Query, pointsRequired int) ([]PassengerPrice, error) {
+	var prices []PassengerPrice
+	for _, pp := range pd.PassengerPricing {
+		if pp.Count <= 0 {
+			continue
+		}
+		p := PassengerPrice{Type: pp.PassengerType, Count: pp.Count}
+		amount := averageAmount(pp.AllPassengerTaxesAndFees.Amount, pp.Count)
+		if q.RedeemPoints {
+			points := 0
+			if pp.PerPassengerPrice != "" {
+				var err error
+				points, err = parseAbbrevInt(pp.PerPassengerPrice)
+				if err != nil {
+					return nil, fmt.Errorf("couldn't parse %s points price %q: %w", pp.PassengerType, pp.PerPassengerPrice, err)
+				}
+			}
+			p.PointsRequired = &points
+			p.TaxesFeesUSD = &amount
+		} else {
+			p.CashPriceUSD = &amount
+		}
+		prices = append(prices, p)
+	}
+	if len(prices) > 0 {
+		return prices, nil
+	}
+
+	// Use the per passenger pricing if there is a single passenger type
+	var types []Passenger
+	for _, p := range q.Passengers {
+		if p.Count > 0 {
+			types = append(types, p)
+		}
+	}
+	if len(types) != 1 {
+		return nil, nil
+	}
+	p := PassengerPrice{Type: types[0].Type, Count: types[0].Count}
+	amount := averageAmount(pd.AllPassengerTaxesAndFees.Amount, types[0].Count)
+	if q.RedeemPoints {
+		p.PointsRequired = &pointsRequired
+		p.TaxesFeesUSD = &amount
+	} else {
+		p.CashPriceUSD = &amount
+	}
+	return []PassengerPrice{p}, nil
+}
+
+// countPassengers returns the total number of passengers. If onlySeated is
+// true, infants in lap are excluded because they don't redeem points.
+func countPassengers(passengers []Passenger, onlySeated bool) int {
+	var n int
+	for _, p := range passengers {
+		if p.Count <= 0 || (onlySeated && p.Type == PassengerInfantLap) {
+			continue
+		}
+		n += p.Count
+	}
+	return n
+}
+
+// averagePoints returns the points per passenger of a total, rounded.
+func averagePoints(total, passengers int) int {
+	if passengers <= 0 {
+		return total
+	}
+	return int(math.Round(float64(total) / float64(passengers)))
+}
+
+// averageAmount returns the amount in USD per passenger of a total, rounded
+// to cents.
+func averageAmount(total float64, passengers int) float64 {
+	if passengers <= 0 {
+		return total
+	}
+	return math.Round(total/float64(passengers)*100) / 100
+}
+
+// newFlight creates a flight from the selected option of each slice.
+// AA prices the options of a follow-up slice as the whole trip: the price of
+// an option is the price of the solutions selected in the previous slices
+// plus the price of that option. So the pricing of the last option is the
+// pricing of the whole trip and the pricing of the previous ones is ignored.
+func newFlight(opts []sliceOption) Flight {
+	last := opts[len(opts)-1]
+	f := Flight{
+		CashPriceUSD:   last.cashPrice,
+		PointsRequired: last.pointsRequired,
+		TaxesFeesUSD:   last.taxesFees,
+		Products:       last.products,
+
+		TotalCashPriceUSD:   last.totalCashPrice,
+		TotalPointsRequired: last.totalPoints,
+		TotalTaxesFeesUSD:   last.totalTaxesFees,
+		PassengerPricing:    last.passengerPricing,
+	}
+	if len(opts) == 1 {
+		f.IsNonstop = last.leg.IsNonstop
+		f.Segments = last.leg.Segments
+		f.TotalDuration = last.leg.TotalDuration
+		return f
+	}
+	f.IsNonstop = true
+	var minutes int
+	for _, opt := range opts {
+		f.Legs = append(f.Legs, opt.leg)
+		f.IsNonstop = f.IsNonstop && opt.leg.IsNonstop
+		minutes += opt.minutes
+	}
+	f.TotalDuration = formatDuration(minutes)
+	return f
+}
+
+// formatDuration formats a duration in minutes as "5h 30m".
+func formatDuration(minutes int) string {
+	return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
 }
 
 // parseAbbrevInt converts strings like "12.5K", "4K", "300" into an int.
@@ -290,11 +812,29 @@ func parseAbbrevInt(s string) (int, error) {
 	return int(math.Round(f * mult)), nil
 }
 
-// parseTime parses a time string in RFC3339Nano format and returns it in "15:04" format.
-func parseTime(s string) (string, error) {
+// parseTime parses a time string in RFC3339Nano format keeping its UTC offset.
+func parseTime(s string) (time.Time, error) {
 	t, err := time.Parse(time.RFC3339Nano, s)
 	if err != nil {
-		return "", fmt.Errorf("couldn't parse time %q: %w", s, err)
+		return time.Time{}, fmt.Errorf("couldn't parse time %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// dayOffset returns the number of calendar days between the local dates of
+// two times.
+func dayOffset(from, to time.Time) int {
+	fy, fm, fd := from.Date()
+	ty, tm, td := to.Date()
+	a := time.Date(fy, fm, fd, 0, 0, 0, 0, time.UTC)
+	b := time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// cityName returns the city name of a location.
+func cityName(l responseLocation) string {
+	if l.CityName != "" {
+		return l.CityName
 	}
-	return t.Format("15:04"), nil
+	return l.City
 }
@@ -0,0 +1,202 @@
+package aa
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/igolaizola/flyaa/pkg/fakeaa"
+)
+
+func TestSearchRoundTripReplay(t *testing.T) {
+	// The cassette was recorded against the fake server, which prices the
+	// return options as the whole trip like AA does
+	c, err := New(&Config{Replay: "testdata/roundtrip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := &Query{
+		Slices: []Slice{
+			{Origin: "LAX", Destination: "JFK", Date: "2025-12-15"},
+			{Origin: "JFK", Destination: "LAX", Date: "2025-12-20"},
+		},
+		Passengers:  []Passenger{{Type: PassengerAdult, Count: 1}},
+		ProductType: "COACH",
+	}
+
+	tests := []struct {
+		name         string
+		redeemPoints bool
+		want         map[string]float64
+	}{
+		{
+			name: "cash",
+			want: map[string]float64{
+				"AA100-AA100":             398,
+				"AA100-AA300_AA301":       338,
+				"AA200_AA201-AA200_AA201": 338,
+				"AA300_AA301-AA300_AA301": 278,
+			},
+		},
+		{
+			name:         "points",
+			redeemPoints: true,
+			want: map[string]float64{
+				"AA100-AA100":             25000,
+				"AA100-AA300_AA301":       20000,
+				"AA200_AA201-AA200_AA201": 20000,
+				"AA300_AA301-AA300_AA301": 15000,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := *q
+			q.RedeemPoints = tt.redeemPoints
+			flights, err := c.search(context.Background(), &q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(flights) != 9 {
+				t.Fatalf("got %d flights, want 9", len(flights))
+			}
+			got := map[string]float64{}
+			for _, f := range flights {
+				if len(f.Legs) != 2 {
+					t.Fatalf("flight %s has %d legs, want 2", f.ID(), len(f.Legs))
+				}
+				switch {
+				case tt.redeemPoints && f.PointsRequired != nil:
+					got[f.ID()] = float64(*f.PointsRequired)
+				case !tt.redeemPoints && f.CashPriceUSD != nil:
+					got[f.ID()] = *f.CashPriceUSD
+				}
+			}
+			for id, want := range tt.want {
+				if got[id] != want {
+					t.Errorf("flight %s: got price %v, want %v", id, got[id], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchMaxFollowUps(t *testing.T) {
+	q := &Query{
+		Slices: []Slice{
+			{Origin: "LAX", Destination: "JFK", Date: "2025-12-15"},
+			{Origin: "JFK", Destination: "MIA", Date: "2025-12-18"},
+			{Origin: "MIA", Destination: "LAX", Date: "2025-12-20"},
+		},
+		Passengers:  []Passenger{{Type: PassengerAdult, Count: 1}},
+		ProductType: "COACH",
+	}
+	tests := []struct {
+		name         string
+		maxFollowUps int
+		wantRequests int
+		wantFlights  int
+		wantFirst    string
+	}{
+		{name: "cheapest option", maxFollowUps: 1, wantRequests: 3, wantFlights: 3, wantFirst: "AA300_AA301-AA300_AA301-AA100"},
+		{name: "two cheapest options", maxFollowUps: 2, wantRequests: 7, wantFlights: 12, wantFirst: "AA300_AA301-AA300_AA301-AA100"},
+		{name: "default", wantRequests: 13, wantFlights: 27, wantFirst: "AA100-AA100-AA100"},
+		{name: "every option", maxFollowUps: -1, wantRequests: 13, wantFlights: 27, wantFirst: "AA100-AA100-AA100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := fakeaa.NewServer(nil)
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+			c, err := New(&Config{BaseURL: srv.URL, Doer: srv.Client(), MaxFollowUps: tt.maxFollowUps})
+			if err != nil {
+				t.Fatal(err)
+			}
+			flights, err := c.search(context.Background(), q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fake.Requests(); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+			if len(flights) != tt.wantFlights {
+				t.Fatalf("got %d flights, want %d", len(flights), tt.wantFlights)
+			}
+			if got := flights[0].ID(); got != tt.wantFirst {
+				t.Errorf("got first flight %s, want %s", got, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestSearchFollowUpBudget(t *testing.T) {
+	// The fake server returns 3 options per slice, so following every one of
+	// the 4 legs sends 1 + 3 + 9 + 27 requests
+	q := &Query{
+		Slices: []Slice{
+			{Origin: "LAX", Destination: "JFK", Date: "2025-12-15"},
+			{Origin: "JFK", Destination: "MIA", Date: "2025-12-18"},
+			{Origin: "MIA", Destination: "ORD", Date: "2025-12-20"},
+			{Origin: "ORD", Destination: "LAX", Date: "2025-12-22"},
+		},
+		Passengers:  []Passenger{{Type: PassengerAdult, Count: 1}},
+		ProductType: "COACH",
+	}
+	tests := []struct {
+		name                string
+		maxFollowUpRequests int
+		wantRequests        int
+		wantFlights         int
+	}{
+		{name: "default", wantRequests: 40, wantFlights: 81},
+		{name: "no limit", maxFollowUpRequests: -1, wantRequests: 40, wantFlights: 81},
+		// The first slice follows its 3 options with a path each and the
+		// request left follows a second option of a third slice
+		{name: "limited", maxFollowUpRequests: 10, wantRequests: 11, wantFlights: 12},
+		{name: "exact paths", maxFollowUpRequests: 9, wantRequests: 10, wantFlights: 9},
+		{name: "under a path", maxFollowUpRequests: 2, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := fakeaa.NewServer(nil)
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+			c, err := New(&Config{BaseURL: srv.URL, Doer: srv.Client(), MaxFollowUpRequests: tt.maxFollowUpRequests})
+			if err != nil {
+				t.Fatal(err)
+			}
+			flights, err := c.search(context.Background(), q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fake.Requests(); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+			if len(flights) != tt.wantFlights {
+				t.Errorf("got %d flights, want %d", len(flights), tt.wantFlights)
+			}
+		})
+	}
+}
+
+func TestAverageAmount(t *testing.T) {
+	tests := []struct {
+		name       string
+		total      float64
+		passengers int
+		want       float64
+	}{
+		{name: "single passenger", total: 199.99, passengers: 1, want: 199.99},
+		{name: "even split", total: 398, passengers: 2, want: 199},
+		{name: "rounded down", total: 370, passengers: 3, want: 123.33},
+		{name: "rounded up", total: 200, passengers: 3, want: 66.67},
+		{name: "no passengers", total: 100, want: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := averageAmount(tt.total, tt.passengers); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package aa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultCacheTTL is how long search results are cached by default.
+const defaultCacheTTL = 10 * time.Minute
+
+// SearchInfo describes how the results of a search were obtained.
+type SearchInfo struct {
+	// CacheHit is true if the results were read from the cache.
+	CacheHit bool `json:"cache_hit"`
+	// CachedAt is when the results were stored in the cache.
+	CachedAt time.Time `json:"cached_at"`
+}
+
+// Search searches the flights of the query, reading and storing the results
+// in the cache of the client if there is one.
+func (c *Client) Search(ctx context.Context, q *Query) ([]Flight, error) {
+	flights, _, err := c.SearchWithInfo(ctx, q)
+	return flights, err
+}
+
+// SearchWithInfo is like Search but it also returns whether the results were
+// cached. The returned info is nil if the client has no cache.
+func (c *Client) SearchWithInfo(ctx context.Context, q *Query) ([]Flight, *SearchInfo, error) {
+	if c.cache == nil {
+		flights, err := c.search(ctx, q)
+		return flights, nil, err
+	}
+	key, err := c.cacheKey(q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Read the results from the cache
+	if !c.refresh {
+		entry, ok, err := c.cache.Get(key)
+		if err != nil {
+			slog.Debug("aa: couldn't read cache", "error", err)
+		}
+		if ok {
+			var flights []Flight
+			if err := json.Unmarshal(entry.Value, &flights); err == nil {
+				return flights, &SearchInfo{CacheHit: true, CachedAt: entry.CreatedAt}, nil
+			}
+		}
+	}
+
+	// Search and store the results
+	flights, err := c.search(ctx, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := json.Marshal(flights)
+	if err != nil {
+		return nil, nil, fmt.Errorf("aa: couldn't marshal cached flights: %w", err)
+	}
+	if err := c.cache.Set(key, value, c.cacheTTL); err != nil {
+		slog.Debug("aa: couldn't write cache", "error", err)
+	}
+	return flights, &SearchInfo{CachedAt: time.Now()}, nil
+}
+
+// cacheKey returns the cache key of a query, built from its normalized
+// parameters and the search type.
+func (c *Client) cacheKey(q *Query) (string, error) {
+	key := struct {
+		BaseURL          string      `json:"base_url"`
+		Slices           []Slice     `json:"slices"`
+		Passengers       []Passenger `json:"passengers"`
+		ProductType      string      `json:"product_type"`
+		Cabin            string      `json:"cabin"`
+		SearchType       string      `json:"search_type"`
+		AllProducts      bool        `json:"all_products"`
+		FollowUps        int         `json:"follow_ups,omitempty"`
+		FollowUpRequests int         `json:"follow_up_requests,omitempty"`
+	}{
+		BaseURL:     c.baseURL,
+		ProductType: strings.ToUpper(q.ProductType),
+		Cabin:       strings.ToUpper(q.Cabin),
+		SearchType:  "revenue",
+		AllProducts: q.AllProducts,
+	}
+	if len(q.Slices) > 1 {
+		key.FollowUps = c.followUps
+		key.FollowUpRequests = c.followUpRequests
+	}
+	if q.RedeemPoints {
+		key.SearchType = "award"
+	}
+	for _, s := range q.Slices {
+		key.Slices = append(key.Slices, Slice{
+			Origin:      strings.ToUpper(s.Origin),
+			Destination: strings.ToUpper(s.Destination),
+			Date:        s.Date,
+		})
+	}
+	for _, p := range q.Passengers {
+		if p.Count > 0 {
+			key.Passengers = append(key.Passengers, p)
+		}
+	}
+	slices.SortFunc(key.Passengers, func(a, b Passenger) int {
+		return strings.Compare(a.Type, b.Type)
+	})
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", fmt.Errorf("aa: couldn't marshal cache key: %w", err)
+	}
+	return "search:" + string(data), nil
+}
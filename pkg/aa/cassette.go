@@ -0,0 +1,224 @@
+package aa
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// cassette is a recorded request and response pair. Only the parts needed to
+// match the request and rebuild the response are stored, so headers with
+// device IDs, cookies or credentials are never written to disk.
+type cassette struct {
+	Request  cassetteRequest  `json:"request"`
+	Response cassetteResponse `json:"response"`
+}
+
+type cassetteRequest struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	Text   string          `json:"text,omitempty"`
+}
+
+type cassetteResponse struct {
+	Status     int             `json:"status"`
+	RetryAfter string          `json:"retry_after,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	Text       string          `json:"text,omitempty"`
+}
+
+// recorder is a Doer that saves each request and response pair in a
+// directory.
+type recorder struct {
+	dir    string
+	prefix string
+	next   Doer
+}
+
+func (r *recorder) Do(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	reqBody = sanitizeBody(reqBody)
+	path := strings.TrimPrefix(req.URL.Path, r.prefix)
+	resp, err := r.next.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("aa: couldn't read response to record: %w", err)
+	}
+
+	// Save the cassette
+	c := cassette{
+		Request: cassetteRequest{
+			Method: req.Method,
+			Path:   path,
+		},
+		Response: cassetteResponse{
+			Status:     resp.StatusCode,
+			RetryAfter: resp.Header.Get("Retry-After"),
+		},
+	}
+	c.Request.Body, c.Request.Text = splitBody(reqBody)
+	c.Response.Body, c.Response.Text = splitBody(respBody)
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("aa: couldn't marshal cassette: %w", err)
+	}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return nil, fmt.Errorf("aa: couldn't create record directory: %w", err)
+	}
+	name := cassetteName(req.Method, path, reqBody)
+	if err := os.WriteFile(filepath.Join(r.dir, name), data, 0644); err != nil {
+		return nil, fmt.Errorf("aa: couldn't write cassette: %w", err)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// replayer is a Doer that serves the responses recorded in a directory
+// without sending any request.
+type replayer struct {
+	dir    string
+	prefix string
+}
+
+func (r *replayer) Do(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	reqBody = sanitizeBody(reqBody)
+	path := strings.TrimPrefix(req.URL.Path, r.prefix)
+	name := cassetteName(req.Method, path, reqBody)
+	data, err := os.ReadFile(filepath.Join(r.dir, name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("aa: no recorded response for %s %s (%s)", req.Method, path, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("aa: couldn't read cassette: %w", err)
+	}
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("aa: couldn't unmarshal cassette %s: %w", name, err)
+	}
+
+	body := []byte(c.Response.Text)
+	if len(c.Response.Body) > 0 {
+		body = c.Response.Body
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	if c.Response.RetryAfter != "" {
+		header.Set("Retry-After", c.Response.RetryAfter)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.Response.Status, http.StatusText(c.Response.Status)),
+		StatusCode:    c.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// readRequestBody reads the body of a request and restores it so it can be
+// sent.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("aa: couldn't read request body: %w", err)
+	}
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// volatileKeys are the request body fields with random values that change on
+// every request.
+var volatileKeys = map[string]struct{}{
+	"transactionID":    {},
+	"bookingSessionID": {},
+}
+
+// sanitizeBody blanks the volatile fields of a JSON request body, so it
+// matches the same request sent later. Other bodies are returned unchanged.
+func sanitizeBody(body []byte) []byte {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	var sanitize func(v any)
+	sanitize = func(v any) {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, e := range t {
+				if _, ok := volatileKeys[k]; ok {
+					t[k] = ""
+					continue
+				}
+				sanitize(e)
+			}
+		case []any:
+			for _, e := range t {
+				sanitize(e)
+			}
+		}
+	}
+	sanitize(v)
+	sanitized, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return sanitized
+}
+
+// splitBody returns the body as raw JSON if it is valid JSON or as text
+// otherwise.
+func splitBody(body []byte) (json.RawMessage, string) {
+	if len(body) == 0 {
+		return nil, ""
+	}
+	if json.Valid(body) {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, body); err == nil {
+			return buf.Bytes(), ""
+		}
+	}
+	return nil, string(body)
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9.]+`)
+
+// cassetteName returns the file name of a request, made of the method, the
+// path relative to the base URL and a hash of the body, so the same request
+// always maps to the same file.
+func cassetteName(method, path string, body []byte) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err == nil {
+		body = compact.Bytes()
+	}
+	sum := sha256.Sum256(body)
+	p := strings.Trim(unsafeChars.ReplaceAllString(path, "_"), "_")
+	return fmt.Sprintf("%s_%s_%s.json", strings.ToLower(method), p, hex.EncodeToString(sum[:])[:16])
+}
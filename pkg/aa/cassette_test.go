@@ -0,0 +1,82 @@
+package aa
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSanitizeBody(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "top level",
+			in:   `{"transactionID":"abc","origin":"LAX"}`,
+			want: `{"origin":"LAX","transactionID":""}`,
+		},
+		{
+			name: "nested",
+			in:   `{"metadata":{"bookingSessionID":"xyz"},"slices":[{"transactionID":"abc","origin":"LAX"}]}`,
+			want: `{"metadata":{"bookingSessionID":""},"slices":[{"origin":"LAX","transactionID":""}]}`,
+		},
+		{
+			name: "without volatile fields",
+			in:   `{"origin":"LAX"}`,
+			want: `{"origin":"LAX"}`,
+		},
+		{
+			name: "not json",
+			in:   `transactionID=abc`,
+			want: `transactionID=abc`,
+		},
+		{
+			name: "empty",
+			in:   ``,
+			want: ``,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(sanitizeBody([]byte(tt.in))); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCassetteName(t *testing.T) {
+	format := regexp.MustCompile(`^post_search_itinerary_v2\.0_[0-9a-f]{16}\.json$`)
+	base := cassetteName("POST", "/search/itinerary/v2.0", []byte(`{"origin":"LAX","destination":"JFK"}`))
+	if !format.MatchString(base) {
+		t.Fatalf("got name %q, want format %s", base, format)
+	}
+	tests := []struct {
+		name     string
+		body     string
+		wantSame bool
+	}{
+		{name: "same body", body: `{"origin":"LAX","destination":"JFK"}`, wantSame: true},
+		{name: "indented body", body: "{\n  \"origin\": \"LAX\",\n  \"destination\": \"JFK\"\n}", wantSame: true},
+		{name: "different body", body: `{"origin":"LAX","destination":"MIA"}`},
+		{name: "no body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cassetteName("POST", "/search/itinerary/v2.0", []byte(tt.body))
+			if !format.MatchString(got) {
+				t.Fatalf("got name %q, want format %s", got, format)
+			}
+			if (got == base) != tt.wantSame {
+				t.Errorf("got name %q for base %q, want same %v", got, base, tt.wantSame)
+			}
+		})
+	}
+	// Volatile fields don't change the name once sanitized
+	a := cassetteName("POST", "/search", sanitizeBody([]byte(`{"transactionID":"a","origin":"LAX"}`)))
+	b := cassetteName("POST", "/search", sanitizeBody([]byte(`{"origin":"LAX","transactionID":"b"}`)))
+	if a != b {
+		t.Errorf("got different names %q and %q for sanitized bodies", a, b)
+	}
+}
@@ -9,18 +9,39 @@ import (
 	"io"
 	"log/slog"
 	"net"
+	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
-	http "github.com/bogdanfinn/fhttp"
 	"github.com/google/uuid"
+	"github.com/igolaizola/flyaa/pkg/cache"
 	"github.com/igolaizola/flyaa/pkg/fhttp"
 )
 
+// Doer sends HTTP requests. It is implemented by *http.Client, so any
+// transport or middleware can be used by the client.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
 type Client struct {
-	client  func() (fhttp.Client, error)
-	debug   bool
-	baseURL string
+	newClient        func(proxy string) (Doer, error)
+	fresh            bool
+	proxies          *proxyPool
+	retry            RetryPolicy
+	limiter          *limiter
+	cache            cache.Cache
+	cacheTTL         time.Duration
+	refresh          bool
+	followUps        int
+	followUpRequests int
+	debug            bool
+	baseURL          string
+
+	lck     sync.Mutex
+	clients map[string]Doer
 }
 
 type Config struct {
@@ -28,97 +49,214 @@ type Config struct {
 	Debug   bool
 	Proxy   string
 	BaseURL string
+	// Proxies are additional proxies. Each request attempt is sent through
+	// one of them, selected with ProxySelection.
+	Proxies []string
+	// ProxySelection is the strategy used to select proxies: round-robin
+	// (default) or random.
+	ProxySelection string
+	// ProxyQuarantine is how long a proxy isn't used after it is blocked,
+	// rate limited or fails to connect. Zero disables the quarantine.
+	ProxyQuarantine time.Duration
+	// Doer is the optional HTTP client used to send the requests. If nil, a
+	// TLS fingerprinting client is created for each proxy. It can't be used
+	// with proxies, which must be configured in the Doer instead.
+	Doer Doer
+	// FreshClient creates a new TLS fingerprinting client for each request
+	// instead of reusing one per proxy.
+	FreshClient bool
+	// Retry is the retry policy of the requests. Unset values use the values
+	// of DefaultRetryPolicy.
+	Retry RetryPolicy
+	// Record is an optional directory where every request and response pair
+	// is saved, without device IDs or credentials.
+	Record string
+	// Replay is an optional directory with recorded responses that are served
+	// instead of sending requests. The base URL isn't required in this mode.
+	Replay string
+	// RateLimit is the maximum number of requests per second, with bursts
+	// of up to RateBurst requests. Zero disables the limit.
+	RateLimit float64
+	RateBurst int
+	// MaxInFlight is the maximum number of concurrent requests. Zero
+	// disables the limit.
+	MaxInFlight int
+	// Cache is an optional cache of search results, valid for CacheTTL (10
+	// minutes by default). CacheRefresh skips reading cached results but
+	// still stores the new ones.
+	Cache        cache.Cache
+	CacheTTL     time.Duration
+	CacheRefresh bool
+	// MaxFollowUps is the maximum number of options of each slice of a
+	// round-trip or multi-city search whose following slices are searched,
+	// cheapest first. Each of them needs a request per following slice. Zero
+	// uses the default of 10 and negative values follow every option.
+	MaxFollowUps int
+	// MaxFollowUpRequests is the maximum number of follow-up requests of a
+	// round-trip or multi-city search across all its slices. Once it is
+	// spent, the remaining options aren't followed. Zero uses the default of
+	// 100 and negative values don't limit the requests.
+	MaxFollowUpRequests int
 }
 
 func New(cfg *Config) (*Client, error) {
 	// Validate input
-	if cfg.BaseURL == "" {
-		return nil, fmt.Errorf("aa: base url is required")
+	if cfg.Record != "" && cfg.Replay != "" {
+		return nil, fmt.Errorf("aa: record and replay can't be used together")
+	}
+	if cfg.Doer != nil && (cfg.Proxy != "" || len(cfg.Proxies) > 0) {
+		return nil, fmt.Errorf("aa: proxies can't be used with a custom doer")
 	}
 	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		if cfg.Replay == "" {
+			return nil, fmt.Errorf("aa: base url is required")
+		}
+		baseURL = "http://replay.invalid"
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("aa: invalid base url: %w", err)
+	}
+	proxies, err := newProxyPool(append([]string{cfg.Proxy}, cfg.Proxies...), cfg.ProxySelection, cfg.ProxyQuarantine)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create http client function
-	client := func() (fhttp.Client, error) {
-		return fhttp.NewClient(1*time.Minute, false, cfg.Proxy, cfg.Debug)
+	newClient := func(proxy string) (Doer, error) {
+		c, err := fhttp.NewClient(1*time.Minute, false, proxy, cfg.Debug)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Client{Transport: c}, nil
+	}
+	if cfg.Doer != nil {
+		newClient = func(string) (Doer, error) {
+			return cfg.Doer, nil
+		}
+	}
+	switch {
+	case cfg.Replay != "":
+		replay := &replayer{dir: cfg.Replay, prefix: u.Path}
+		newClient = func(string) (Doer, error) {
+			return replay, nil
+		}
+	case cfg.Record != "":
+		next := newClient
+		newClient = func(proxy string) (Doer, error) {
+			d, err := next(proxy)
+			if err != nil {
+				return nil, err
+			}
+			return &recorder{dir: cfg.Record, prefix: u.Path, next: d}, nil
+		}
+	}
+
+	cacheTTL := cfg.CacheTTL
+	if cacheTTL <= 0 {
+		cacheTTL = defaultCacheTTL
+	}
+
+	followUps := cfg.MaxFollowUps
+	if followUps == 0 {
+		followUps = defaultMaxFollowUps
+	}
+	followUpRequests := cfg.MaxFollowUpRequests
+	if followUpRequests == 0 {
+		followUpRequests = defaultMaxFollowUpRequests
 	}
 
 	return &Client{
-		baseURL: baseURL,
-		client:  client,
-		debug:   cfg.Debug,
+		baseURL:          baseURL,
+		newClient:        newClient,
+		fresh:            cfg.FreshClient && cfg.Doer == nil && cfg.Replay == "",
+		proxies:          proxies,
+		retry:            cfg.Retry.withDefaults(),
+		limiter:          newLimiter(cfg.RateLimit, cfg.RateBurst, cfg.MaxInFlight),
+		cache:            cfg.Cache,
+		cacheTTL:         cacheTTL,
+		refresh:          cfg.CacheRefresh,
+		followUps:        followUps,
+		followUpRequests: followUpRequests,
+		debug:            cfg.Debug,
+		clients:          map[string]Doer{},
 	}, nil
 }
 
-var backoff = []time.Duration{
-	100 * time.Millisecond,
-	500 * time.Millisecond,
-	1 * time.Second,
+// client returns the HTTP client used to send requests through the proxy.
+// Clients are created once per proxy and reused, so their connections are
+// kept alive, unless fresh clients are enabled.
+func (c *Client) client(proxy string) (Doer, error) {
+	if c.fresh {
+		return c.newClient(proxy)
+	}
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	if client, ok := c.clients[proxy]; ok {
+		return client, nil
+	}
+	client, err := c.newClient(proxy)
+	if err != nil {
+		return nil, err
+	}
+	c.clients[proxy] = client
+	return client, nil
 }
 
 func (c *Client) do(ctx context.Context, method, path string, in, out any) ([]byte, error) {
-	maxAttempts := len(backoff) + 1
+	maxAttempts := c.retry.MaxAttempts
 	attempts := 0
 	var err error
+	var proxy string
 	for {
 		if err != nil {
 			slog.Debug("service: retrying request", "attempt", attempts+1, "error", err)
 		}
 		var b []byte
-		b, err = c.doAttempt(ctx, method, path, in, out)
+		release, lerr := c.limiter.acquire(ctx)
+		if lerr != nil {
+			return nil, lerr
+		}
+		proxy = c.proxies.pick(proxy)
+		b, err = c.doAttempt(ctx, proxy, method, path, in, out)
+		release()
+		if ctx.Err() == nil {
+			c.proxies.report(proxy, err)
+		}
 		if err == nil {
 			return b, nil
 		}
 		// Increase attempts and check if we should stop
 		attempts++
 		if attempts >= maxAttempts {
-			return nil, err
+			return nil, blockedError(err)
 		}
 
 		// Check if we should retry after waiting
-		var retry bool
-		var wait bool
-
-		// Check for timeout error
-		var netErr net.Error
-		if errors.As(err, &netErr) && netErr.Timeout() {
-			retry = true
-		}
-
-		// Check status code
-		var errStatus errStatusCode
-		if errors.As(err, &errStatus) {
-			switch int(errStatus) {
-			case http.StatusBadGateway, http.StatusGatewayTimeout, http.StatusTooManyRequests,
-				http.StatusInternalServerError, 520, 522,
-				http.StatusForbidden, http.StatusBadRequest:
-				// Retry on these status codes
-				retry = true
-				wait = true
-			}
-		}
-
-		// Check for EOF errors or proxy errors
-		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
-			strings.Contains(strings.ToLower(err.Error()), "proxy responded with non 200 code") {
-			retry = true
-		}
+		retry, wait := c.classify(err)
 
 		// Stop if we shouldn't retry
 		if !retry {
-			return nil, err
+			return nil, blockedError(err)
 		}
 
 		// Wait before retrying if needed
 		if wait {
-			idx := attempts - 1
-			if idx >= len(backoff) {
-				idx = len(backoff) - 1
+			waitTime := c.retry.delay(attempts)
+			var errStatus *errStatusCode
+			if errors.As(err, &errStatus) && errStatus.retryAfter > waitTime {
+				if errStatus.retryAfter > c.retry.RetryAfterLimit {
+					// Fail fast instead of waiting too long
+					return nil, blockedError(fmt.Errorf("service: retry after %s is over the %s limit: %w", errStatus.retryAfter, c.retry.RetryAfterLimit, err))
+				}
+				waitTime = errStatus.retryAfter
 			}
-			waitTime := backoff[idx]
 			slog.Debug("service: waiting before retrying request", "waitTime", waitTime)
 			t := time.NewTimer(waitTime)
 			select {
 			case <-ctx.Done():
+				t.Stop()
 				return nil, ctx.Err()
 			case <-t.C:
 			}
@@ -126,13 +264,57 @@ func (c *Client) do(ctx context.Context, method, path string, in, out any) ([]by
 	}
 }
 
-type errStatusCode int
+// classify returns whether a failed request should be retried and whether
+// it should wait before retrying.
+func (c *Client) classify(err error) (bool, bool) {
+	// Check the custom classifier
+	if c.retry.Classifier != nil {
+		if retry, handled := c.retry.Classifier(err); handled {
+			return retry, retry
+		}
+	}
+
+	// Check for timeout error
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true, false
+	}
+
+	// Rotate to another proxy on connection errors
+	if c.proxies.size() > 1 && errors.As(err, &netErr) {
+		return true, false
+	}
+
+	// Check status code
+	var errStatus *errStatusCode
+	if errors.As(err, &errStatus) {
+		return c.retry.retryStatus(errStatus.code), true
+	}
+
+	// Check for EOF errors or proxy errors
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		strings.Contains(strings.ToLower(err.Error()), "proxy responded with non 200 code") {
+		return true, false
+	}
+	return false, false
+}
+
+type errStatusCode struct {
+	code       int
+	retryAfter time.Duration
+}
+
+func (e *errStatusCode) Error() string {
+	return fmt.Sprintf("%d", e.code)
+}
 
-func (e errStatusCode) Error() string {
-	return fmt.Sprintf("%d", e)
+// ProxyStats returns the number of successful and failed requests of each
+// proxy.
+func (c *Client) ProxyStats() []ProxyStats {
+	return c.proxies.stats()
 }
 
-func (c *Client) doAttempt(ctx context.Context, method, path string, in, out any) ([]byte, error) {
+func (c *Client) doAttempt(ctx context.Context, proxy, method, path string, in, out any) ([]byte, error) {
 	// Prepare request body
 	var body []byte
 	var reqBody io.Reader
@@ -158,7 +340,7 @@ func (c *Client) doAttempt(ctx context.Context, method, path string, in, out any
 	c.addHeaders(req)
 
 	// Do request
-	client, err := c.client()
+	client, err := c.client(proxy)
 	if err != nil {
 		return nil, fmt.Errorf("service: couldn't create http client: %w", err)
 	}
@@ -182,7 +364,11 @@ func (c *Client) doAttempt(ctx context.Context, method, path string, in, out any
 		if len(errMessage) > 100 {
 			errMessage = errMessage[:100] + "..."
 		}
-		return nil, fmt.Errorf("service: %s %s returned (%s): %w", method, u, errMessage, errStatusCode(resp.StatusCode))
+		errStatus := &errStatusCode{code: resp.StatusCode}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			errStatus.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return nil, fmt.Errorf("service: %s %s returned (%s): %w", method, u, errMessage, errStatus)
 	}
 
 	// Unmarshal response
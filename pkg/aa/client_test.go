@@ -0,0 +1,165 @@
+package aa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/igolaizola/flyaa/pkg/fakeaa"
+)
+
+// newFakeClient starts a fake server with the scenario and returns a client
+// that sends its requests to it.
+func newFakeClient(t *testing.T, scenario string, retry RetryPolicy) (*Client, *fakeaa.Server) {
+	t.Helper()
+	s, err := fakeaa.LoadScenario(scenario)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := fakeaa.NewServer(s)
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	c, err := New(&Config{
+		BaseURL: srv.URL,
+		Doer:    srv.Client(),
+		Retry:   retry,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, fake
+}
+
+func oneWayQuery(origin string, redeemPoints bool) *Query {
+	return &Query{
+		Slices:       []Slice{{Origin: origin, Destination: "JFK", Date: "2025-12-15"}},
+		Passengers:   []Passenger{{Type: PassengerAdult, Count: 1}},
+		ProductType:  "COACH",
+		RedeemPoints: redeemPoints,
+	}
+}
+
+func TestClientRetryFlaky(t *testing.T) {
+	// The flaky scenario fails the first requests with two 429 asking to
+	// retry after 1 second, a 403 and a 503
+	tests := []struct {
+		name         string
+		retry        RetryPolicy
+		wantErr      error
+		wantRequests int
+		minElapsed   time.Duration
+	}{
+		{
+			name:         "retries until success honoring retry after",
+			retry:        RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+			wantRequests: 5,
+			minElapsed:   2 * time.Second,
+		},
+		{
+			name:         "rate limited after exhausting attempts",
+			retry:        RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+			wantErr:      ErrBlocked,
+			wantRequests: 2,
+			minElapsed:   time.Second,
+		},
+		{
+			name:         "fails fast when retry after is over the limit",
+			retry:        RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, RetryAfterLimit: 500 * time.Millisecond},
+			wantErr:      ErrBlocked,
+			wantRequests: 1,
+		},
+		{
+			name:         "status not retried",
+			retry:        RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, RetryStatus: []int{503}},
+			wantErr:      ErrBlocked,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, fake := newFakeClient(t, "flaky", tt.retry)
+			start := time.Now()
+			flights, err := c.Search(context.Background(), oneWayQuery("LAX", false))
+			elapsed := time.Since(start)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && len(flights) != 1 {
+				t.Errorf("got %d flights, want 1", len(flights))
+			}
+			if got := fake.Requests(); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+			if elapsed < tt.minElapsed {
+				t.Errorf("took %s, want at least %s", elapsed, tt.minElapsed)
+			}
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		origin       string
+		redeemPoints bool
+		wantErr      error
+	}{
+		{name: "no flights", origin: "ERR", wantErr: ErrNoAvailability},
+		{name: "invalid market", origin: "MKT", wantErr: ErrInvalidMarket},
+		{name: "session expired", origin: "SES", wantErr: ErrSessionExpired},
+		{name: "blocked status", origin: "BLK", wantErr: ErrBlocked},
+		{name: "no awards", origin: "LAX", redeemPoints: true, wantErr: ErrNoAvailability},
+		{name: "cash flights", origin: "LAX"},
+	}
+	c, _ := newFakeClient(t, "errors", RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flights, err := c.Search(context.Background(), oneWayQuery(tt.origin, tt.redeemPoints))
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(flights) != 1 {
+					t.Fatalf("got %d flights, want 1", len(flights))
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			// Errors must match a single class
+			for _, other := range []error{ErrNoAvailability, ErrInvalidMarket, ErrSessionExpired, ErrBlocked} {
+				if other != tt.wantErr && errors.Is(err, other) {
+					t.Errorf("error %v also matches %v", err, other)
+				}
+			}
+		})
+	}
+}
+
+func TestNewDoerWithProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "doer", cfg: Config{BaseURL: "http://localhost", Doer: http.DefaultClient}},
+		{name: "proxies", cfg: Config{BaseURL: "http://localhost", Proxies: []string{"http://proxy:8080"}}},
+		{name: "doer and proxy", cfg: Config{BaseURL: "http://localhost", Doer: http.DefaultClient, Proxy: "http://proxy:8080"}, wantErr: true},
+		{name: "doer and proxies", cfg: Config{BaseURL: "http://localhost", Doer: http.DefaultClient, Proxies: []string{"http://proxy:8080"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
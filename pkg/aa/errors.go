@@ -0,0 +1,162 @@
+package aa
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Errors returned by the AA API inside the response body. ErrBlocked is also
+// returned when requests are still rejected with a 403 or a 429 status code
+// after retrying them.
+var (
+	ErrNoAvailability = errors.New("aa: no availability")
+	ErrInvalidMarket  = errors.New("aa: invalid market")
+	ErrSessionExpired = errors.New("aa: session expired")
+	ErrBlocked        = errors.New("aa: blocked")
+	ErrUnknown        = errors.New("aa: unknown error")
+)
+
+// APIError is an error returned by the AA API in the error field of a
+// response. It wraps one of the ErrXxx errors of this package, so it can be
+// checked with errors.Is.
+type APIError struct {
+	Kind    error
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	var details []string
+	if e.Code != "" {
+		details = append(details, e.Code)
+	}
+	if e.Message != "" {
+		details = append(details, e.Message)
+	}
+	if len(details) == 0 {
+		return e.Kind.Error()
+	}
+	return fmt.Sprintf("%s (%s)", e.Kind, strings.Join(details, ": "))
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+// errorCodes maps the error codes of the API to the kind of error.
+var errorCodes = map[string]error{
+	"NO_FLIGHTS_FOUND":    ErrNoAvailability,
+	"NO_FLIGHTS":          ErrNoAvailability,
+	"NO_AWARDS":           ErrNoAvailability,
+	"NO_AVAILABILITY":     ErrNoAvailability,
+	"NO_RESULTS":          ErrNoAvailability,
+	"INVALID_MARKET":      ErrInvalidMarket,
+	"MARKET_NOT_SERVED":   ErrInvalidMarket,
+	"INVALID_ORIGIN":      ErrInvalidMarket,
+	"INVALID_DESTINATION": ErrInvalidMarket,
+	"SESSION_EXPIRED":     ErrSessionExpired,
+	"SESSION_TIMEOUT":     ErrSessionExpired,
+	"INVALID_SESSION":     ErrSessionExpired,
+	"BLOCKED":             ErrBlocked,
+	"ACCESS_DENIED":       ErrBlocked,
+	"FORBIDDEN":           ErrBlocked,
+	"UNAUTHORIZED":        ErrBlocked,
+	"CAPTCHA_REQUIRED":    ErrBlocked,
+}
+
+// errorPhrases maps phrases found in the error message to the kind of error,
+// for errors without a known code. The first matching class is used.
+var errorPhrases = []struct {
+	kind    error
+	phrases []string
+}{
+	{ErrNoAvailability, []string{"no flights", "no availability", "not available", "no results", "no itineraries", "sold out"}},
+	{ErrInvalidMarket, []string{"invalid market", "market not served", "invalid origin", "invalid destination", "invalid city", "invalid airport"}},
+	{ErrSessionExpired, []string{"session expired", "session has expired", "session timed out", "session timeout", "invalid session"}},
+	{ErrBlocked, []string{"blocked", "forbidden", "access denied", "captcha", "unauthorized"}},
+}
+
+// parseAPIError parses the error field of a response. It returns nil if the
+// field is empty.
+func parseAPIError(v any) *APIError {
+	var code, message string
+	switch e := v.(type) {
+	case nil:
+		return nil
+	case bool:
+		if !e {
+			return nil
+		}
+	case string:
+		message = strings.TrimSpace(e)
+		if message == "" {
+			return nil
+		}
+	case []any:
+		if len(e) == 0 {
+			return nil
+		}
+		return parseAPIError(e[0])
+	case map[string]any:
+		if len(e) == 0 {
+			return nil
+		}
+		if errs, ok := e["errors"]; ok {
+			if apiErr := parseAPIError(errs); apiErr != nil {
+				return apiErr
+			}
+		}
+		code = firstString(e, "code", "errorCode", "errorNumber", "type")
+		message = firstString(e, "message", "errorMessage", "description", "title", "detail")
+		if code == "" && message == "" {
+			return nil
+		}
+	default:
+		message = fmt.Sprintf("%v", e)
+	}
+
+	// Classify the error by its code and then by its message
+	apiErr := &APIError{Kind: ErrUnknown, Code: code, Message: message}
+	normalized := strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToUpper(code))
+	if kind, ok := errorCodes[normalized]; ok {
+		apiErr.Kind = kind
+		return apiErr
+	}
+	text := strings.ToLower(message)
+	for _, c := range errorPhrases {
+		for _, p := range c.phrases {
+			if strings.Contains(text, p) {
+				apiErr.Kind = c.kind
+				return apiErr
+			}
+		}
+	}
+	return apiErr
+}
+
+// blockedError wraps the error of a request rejected with a 403 or a 429
+// status code that isn't retried anymore, so it matches ErrBlocked.
+func blockedError(err error) error {
+	var errStatus *errStatusCode
+	if errors.As(err, &errStatus) && (errStatus.code == http.StatusForbidden || errStatus.code == http.StatusTooManyRequests) {
+		return fmt.Errorf("%w: %w", ErrBlocked, err)
+	}
+	return err
+}
+
+// firstString returns the first non-empty value of the keys as a string.
+func firstString(m map[string]any, keys ...string) string {
+	for _, k := range keys {
+		v, ok := m[k]
+		if !ok || v == nil {
+			continue
+		}
+		s := strings.TrimSpace(fmt.Sprintf("%v", v))
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package aa
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAPIError(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want error
+	}{
+		{name: "nil", in: nil},
+		{name: "false", in: false},
+		{name: "empty string", in: " "},
+		{name: "empty list", in: []any{}},
+		{name: "empty object", in: map[string]any{}},
+		{name: "object without code or message", in: map[string]any{"foo": "bar"}},
+		{name: "code", in: map[string]any{"code": "NO_FLIGHTS_FOUND"}, want: ErrNoAvailability},
+		{name: "code before message", in: map[string]any{"code": "INVALID_MARKET", "message": "No flights found"}, want: ErrInvalidMarket},
+		{name: "numeric code with message", in: map[string]any{"errorCode": 1234, "errorMessage": "Invalid market"}, want: ErrInvalidMarket},
+		{name: "no flights for route", in: map[string]any{"message": "No flights found for this route"}, want: ErrNoAvailability},
+		{name: "string message", in: "Session has expired", want: ErrSessionExpired},
+		{name: "session without expiry", in: "Couldn't create booking session", want: ErrUnknown},
+		{name: "expired fare", in: "The fare has expired", want: ErrUnknown},
+		{name: "blocked", in: map[string]any{"code": "ACCESS_DENIED"}, want: ErrBlocked},
+		{name: "list", in: []any{map[string]any{"code": "NO_AWARDS", "message": "No availability for this search"}}, want: ErrNoAvailability},
+		{name: "nested errors", in: map[string]any{"errors": []any{map[string]any{"code": "session-expired"}}}, want: ErrSessionExpired},
+		{name: "unknown", in: map[string]any{"code": "E42", "message": "Something went wrong"}, want: ErrUnknown},
+		{name: "true", in: true, want: ErrUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseAPIError(tt.in)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("got %v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("got nil, want %v", tt.want)
+			}
+			if !errors.Is(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockedError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "forbidden", err: &errStatusCode{code: 403}, want: true},
+		{name: "too many requests", err: &errStatusCode{code: 429}, want: true},
+		{name: "server error", err: &errStatusCode{code: 500}},
+		{name: "other error", err: errors.New("eof")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(blockedError(tt.err), ErrBlocked); got != tt.want {
+				t.Errorf("got blocked %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package aa
+
+import (
+	"context"
+
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
+)
+
+// limiter limits the rate and the number of concurrent requests of a client.
+// It is shared by all the searches of the client.
+type limiter struct {
+	rate     *rate.Limiter
+	inFlight *semaphore.Weighted
+}
+
+// newLimiter creates a limiter of rps requests per second with the given
+// burst and a maximum of maxInFlight concurrent requests. Zero values disable
+// each limit.
+func newLimiter(rps float64, burst, maxInFlight int) *limiter {
+	l := &limiter{}
+	if rps > 0 {
+		if burst <= 0 {
+			burst = 1
+		}
+		l.rate = rate.NewLimiter(rate.Limit(rps), burst)
+	}
+	if maxInFlight > 0 {
+		l.inFlight = semaphore.NewWeighted(int64(maxInFlight))
+	}
+	return l
+}
+
+// acquire waits until a request can be sent. The returned function must be
+// called when the request finishes.
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if l.inFlight != nil {
+		if err := l.inFlight.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+	}
+	release := func() {
+		if l.inFlight != nil {
+			l.inFlight.Release(1)
+		}
+	}
+	if l.rate != nil {
+		if err := l.rate.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
@@ -0,0 +1,196 @@
+package aa
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Proxy selection strategies.
+const (
+	ProxyRoundRobin = "round-robin"
+	ProxyRandom     = "random"
+)
+
+// ProxyStats contains the number of successful and failed requests sent
+// through a proxy.
+type ProxyStats struct {
+	Proxy       string `json:"proxy"`
+	Successes   int    `json:"successes"`
+	Failures    int    `json:"failures"`
+	Quarantines int    `json:"quarantines"`
+}
+
+// proxyPool selects the proxy of each request, rotating between them and
+// quarantining the ones that fail.
+type proxyPool struct {
+	lck        sync.Mutex
+	proxies    []*proxyState
+	random     bool
+	next       int
+	quarantine time.Duration
+}
+
+type proxyState struct {
+	ProxyStats
+	url   string
+	until time.Time
+}
+
+func newProxyPool(proxies []string, selection string, quarantine time.Duration) (*proxyPool, error) {
+	p := &proxyPool{quarantine: quarantine}
+	switch selection {
+	case "", ProxyRoundRobin:
+	case ProxyRandom:
+		p.random = true
+	default:
+		return nil, fmt.Errorf("aa: unsupported proxy selection %q", selection)
+	}
+	seen := map[string]struct{}{}
+	for _, u := range proxies {
+		u = strings.TrimSpace(u)
+		if u == "" {
+			continue
+		}
+		if _, ok := seen[u]; ok {
+			continue
+		}
+		if _, err := url.Parse(u); err != nil {
+			// The parse error includes the URL and its password
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return nil, fmt.Errorf("aa: invalid proxy %q: %w", redactProxy(u), err)
+		}
+		seen[u] = struct{}{}
+		p.proxies = append(p.proxies, &proxyState{
+			ProxyStats: ProxyStats{Proxy: redactProxy(u)},
+			url:        u,
+		})
+	}
+	if len(p.proxies) > 0 {
+		p.next = rand.IntN(len(p.proxies))
+	}
+	return p, nil
+}
+
+// pick returns the proxy of the next request, avoiding the previous one and
+// quarantined proxies when possible. It returns an empty string if there are
+// no proxies.
+func (p *proxyPool) pick(previous string) string {
+	p.lck.Lock()
+	defer p.lck.Unlock()
+	n := len(p.proxies)
+	switch n {
+	case 0:
+		return ""
+	case 1:
+		return p.proxies[0].url
+	}
+
+	// Look for an available proxy starting at the next or a random one
+	start := p.next
+	if p.random {
+		start = rand.IntN(n)
+	}
+	now := time.Now()
+	var fallback *proxyState
+	for i := 0; i < n; i++ {
+		candidate := p.proxies[(start+i)%n]
+		if candidate.url == previous {
+			continue
+		}
+		if candidate.until.After(now) {
+			// Keep the proxy with the closest end of quarantine as fallback
+			if fallback == nil || candidate.until.Before(fallback.until) {
+				fallback = candidate
+			}
+			continue
+		}
+		p.next = (start + i + 1) % n
+		return candidate.url
+	}
+	if fallback == nil {
+		return previous
+	}
+	return fallback.url
+}
+
+// report records the result of a request sent through a proxy. Proxies that
+// fail because of blocking, rate limiting or connection errors are
+// quarantined.
+func (p *proxyPool) report(proxy string, err error) {
+	if proxy == "" {
+		return
+	}
+	p.lck.Lock()
+	defer p.lck.Unlock()
+	for _, s := range p.proxies {
+		if s.url != proxy {
+			continue
+		}
+		if err == nil {
+			s.Successes++
+			return
+		}
+		s.Failures++
+		if isProxyFailure(err) && p.quarantine > 0 {
+			s.Quarantines++
+			s.until = time.Now().Add(p.quarantine)
+		}
+		return
+	}
+}
+
+// size returns the number of proxies.
+func (p *proxyPool) size() int {
+	return len(p.proxies)
+}
+
+// stats returns the stats of each proxy.
+func (p *proxyPool) stats() []ProxyStats {
+	p.lck.Lock()
+	defer p.lck.Unlock()
+	var stats []ProxyStats
+	for _, s := range p.proxies {
+		stats = append(stats, s.ProxyStats)
+	}
+	return stats
+}
+
+// isProxyFailure returns whether an error is likely caused by the proxy, so
+// the request should be rotated to another one.
+func isProxyFailure(err error) bool {
+	var errStatus *errStatusCode
+	if errors.As(err, &errStatus) {
+		return errStatus.code == http.StatusForbidden || errStatus.code == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "proxy responded with non 200 code")
+}
+
+// redactProxy removes the password of a proxy URL. The credentials of URLs
+// that can't be parsed are removed entirely.
+func redactProxy(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		if i := strings.LastIndex(u, "@"); i >= 0 {
+			return "xxxxx@" + u[i+1:]
+		}
+		return u
+	}
+	if parsed.User == nil {
+		return u
+	}
+	return parsed.Redacted()
+}
@@ -0,0 +1,114 @@
+package aa
+
+import (
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy configures how failed requests are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of a request, including
+	// the first one.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry. It is doubled on each
+	// retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter is the fraction of the delay, between 0 and 1, that is randomly
+	// added or subtracted to it. Zero disables it.
+	Jitter float64
+	// RetryAfterLimit is the maximum wait requested by a Retry-After header.
+	// Requests asking for longer waits aren't retried.
+	RetryAfterLimit time.Duration
+	// RetryStatus is the set of HTTP status codes that are retried.
+	RetryStatus []int
+	// Classifier optionally decides whether an error is retried before the
+	// default rules are applied. It returns whether to retry and false if it
+	// doesn't handle the error.
+	Classifier func(err error) (retry bool, handled bool)
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     4,
+		BaseDelay:       100 * time.Millisecond,
+		MaxDelay:        1 * time.Second,
+		Jitter:          0.2,
+		RetryAfterLimit: 1 * time.Minute,
+		RetryStatus: []int{
+			http.StatusForbidden, http.StatusTooManyRequests,
+			http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+			520, 522,
+		},
+	}
+}
+
+// withDefaults fills the unset values of the policy with the default ones.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	def := DefaultRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = def.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = def.MaxDelay
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	p.Jitter = math.Min(math.Max(p.Jitter, 0), 1)
+	if p.RetryAfterLimit <= 0 {
+		p.RetryAfterLimit = def.RetryAfterLimit
+	}
+	if p.RetryStatus == nil {
+		p.RetryStatus = def.RetryStatus
+	}
+	return p
+}
+
+// retryStatus returns whether the status code must be retried.
+func (p RetryPolicy) retryStatus(code int) bool {
+	return slices.Contains(p.RetryStatus, code)
+}
+
+// delay returns the wait before the given retry, starting at 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := float64(p.BaseDelay) * math.Pow(2, float64(retry-1))
+	d = math.Min(d, float64(p.MaxDelay))
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// parseRetryAfter parses a Retry-After header value, either in seconds or as
+// an HTTP date. It returns zero if the value is empty or invalid.
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0
+	}
+	if d := time.Until(t); d > 0 {
+		return d
+	}
+	return 0
+}
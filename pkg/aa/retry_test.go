@@ -0,0 +1,95 @@
+package aa
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		retry    int
+		min, max time.Duration
+	}{
+		{name: "first retry", policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}, retry: 1, min: 100 * time.Millisecond, max: 100 * time.Millisecond},
+		{name: "doubles on each retry", policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}, retry: 3, min: 400 * time.Millisecond, max: 400 * time.Millisecond},
+		{name: "capped at max delay", policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}, retry: 10, min: time.Second, max: time.Second},
+		{name: "jitter", policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.5}, retry: 2, min: 100 * time.Millisecond, max: 300 * time.Millisecond},
+		{name: "jitter over max delay", policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.2}, retry: 10, min: 800 * time.Millisecond, max: 1200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.policy.withDefaults()
+			for range 100 {
+				if got := p.delay(tt.retry); got < tt.min || got > tt.max {
+					t.Fatalf("got delay %s, want between %s and %s", got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyWithDefaults(t *testing.T) {
+	def := DefaultRetryPolicy()
+	tests := []struct {
+		name            string
+		policy          RetryPolicy
+		wantJitter      float64
+		wantMaxDelay    time.Duration
+		wantRetryStatus []int
+	}{
+		{name: "zero jitter disables it", policy: RetryPolicy{Jitter: 0}, wantJitter: 0, wantMaxDelay: def.MaxDelay, wantRetryStatus: def.RetryStatus},
+		{name: "default jitter", policy: RetryPolicy{Jitter: def.Jitter}, wantJitter: def.Jitter, wantMaxDelay: def.MaxDelay, wantRetryStatus: def.RetryStatus},
+		{name: "negative jitter", policy: RetryPolicy{Jitter: -1}, wantJitter: 0, wantMaxDelay: def.MaxDelay, wantRetryStatus: def.RetryStatus},
+		{name: "jitter over one", policy: RetryPolicy{Jitter: 2}, wantJitter: 1, wantMaxDelay: def.MaxDelay, wantRetryStatus: def.RetryStatus},
+		{name: "max delay under base delay", policy: RetryPolicy{BaseDelay: 5 * time.Second, MaxDelay: time.Second}, wantJitter: 0, wantMaxDelay: 5 * time.Second, wantRetryStatus: def.RetryStatus},
+		{name: "custom status", policy: RetryPolicy{RetryStatus: []int{http.StatusServiceUnavailable}}, wantJitter: 0, wantMaxDelay: def.MaxDelay, wantRetryStatus: []int{http.StatusServiceUnavailable}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.policy.withDefaults()
+			if p.Jitter != tt.wantJitter {
+				t.Errorf("got jitter %v, want %v", p.Jitter, tt.wantJitter)
+			}
+			if p.MaxDelay != tt.wantMaxDelay {
+				t.Errorf("got max delay %s, want %s", p.MaxDelay, tt.wantMaxDelay)
+			}
+			if p.RetryAfterLimit != def.RetryAfterLimit {
+				t.Errorf("got retry after limit %s, want %s", p.RetryAfterLimit, def.RetryAfterLimit)
+			}
+			for _, code := range tt.wantRetryStatus {
+				if !p.retryStatus(code) {
+					t.Errorf("status %d isn't retried", code)
+				}
+			}
+			if p.retryStatus(http.StatusBadRequest) {
+				t.Errorf("status %d is retried", http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		min, max time.Duration
+	}{
+		{name: "empty", in: ""},
+		{name: "seconds", in: "2", min: 2 * time.Second, max: 2 * time.Second},
+		{name: "seconds with spaces", in: " 30 ", min: 30 * time.Second, max: 30 * time.Second},
+		{name: "negative", in: "-1"},
+		{name: "invalid", in: "soon"},
+		{name: "future date", in: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), min: 58 * time.Second, max: time.Minute},
+		{name: "past date", in: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.in); got < tt.min || got > tt.max {
+				t.Errorf("got %s, want between %s and %s", got, tt.min, tt.max)
+			}
+		})
+	}
+}
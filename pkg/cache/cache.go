@@ -0,0 +1,35 @@
+// Package cache implements the backends used to cache search responses.
+package cache
+
+import (
+	"time"
+)
+
+// Cache stores values by key until they expire.
+type Cache interface {
+	// Get returns the entry of a key. It returns false if the key isn't
+	// cached or has expired.
+	Get(key string) (Entry, bool, error)
+	// Set stores a value that expires after the TTL.
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+// Entry is a cached value.
+type Entry struct {
+	Value     []byte    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func newEntry(value []byte, ttl time.Duration) Entry {
+	now := time.Now()
+	return Entry{
+		Value:     value,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+func (e Entry) expired() bool {
+	return !time.Now().Before(e.ExpiresAt)
+}
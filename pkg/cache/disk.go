@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Disk is a cache that stores each entry in a file of a directory, so it is
+// shared between runs.
+type Disk struct {
+	dir string
+}
+
+// NewDisk creates a disk cache in the directory, creating it if needed.
+func NewDisk(dir string) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cache: couldn't create directory: %w", err)
+	}
+	return &Disk{dir: dir}, nil
+}
+
+func (d *Disk) Get(key string) (Entry, bool, error) {
+	path := d.path(key)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("cache: couldn't read entry: %w", err)
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		// Discard corrupted entries
+		_ = os.Remove(path)
+		return Entry{}, false, nil
+	}
+	if entry.expired() {
+		_ = os.Remove(path)
+		return Entry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+func (d *Disk) Set(key string, value []byte, ttl time.Duration) error {
+	data, err := json.Marshal(newEntry(value, ttl))
+	if err != nil {
+		return fmt.Errorf("cache: couldn't marshal entry: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see partial entries
+	tmp, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("cache: couldn't create entry: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("cache: couldn't write entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cache: couldn't write entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), d.path(key)); err != nil {
+		return fmt.Errorf("cache: couldn't write entry: %w", err)
+	}
+	return nil
+}
+
+// path returns the file of a key.
+func (d *Disk) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Memory is an in-memory cache that evicts the least recently used entries
+// when it is full.
+type Memory struct {
+	lck     sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	entry Entry
+}
+
+// NewMemory creates an in-memory cache with up to size entries. Zero or
+// negative sizes don't limit the number of entries.
+func NewMemory(size int) *Memory {
+	return &Memory{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (m *Memory) Get(key string) (Entry, bool, error) {
+	m.lck.Lock()
+	defer m.lck.Unlock()
+	elem, ok := m.entries[key]
+	if !ok {
+		return Entry{}, false, nil
+	}
+	item := elem.Value.(*memoryItem)
+	if item.entry.expired() {
+		m.order.Remove(elem)
+		delete(m.entries, key)
+		return Entry{}, false, nil
+	}
+	m.order.MoveToFront(elem)
+	return item.entry, true, nil
+}
+
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) error {
+	m.lck.Lock()
+	defer m.lck.Unlock()
+	entry := newEntry(value, ttl)
+	if elem, ok := m.entries[key]; ok {
+		elem.Value.(*memoryItem).entry = entry
+		m.order.MoveToFront(elem)
+		return nil
+	}
+	m.entries[key] = m.order.PushFront(&memoryItem{key: key, entry: entry})
+
+	// Evict the least recently used entries
+	for m.size > 0 && m.order.Len() > m.size {
+		last := m.order.Back()
+		m.order.Remove(last)
+		delete(m.entries, last.Value.(*memoryItem).key)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	type op struct {
+		get, set string
+		ttl      time.Duration
+	}
+	tests := []struct {
+		name string
+		size int
+		ops  []op
+		want []string
+	}{
+		{
+			name: "evicts least recently set",
+			size: 2,
+			ops:  []op{{set: "a"}, {set: "b"}, {set: "c"}},
+			want: []string{"b", "c"},
+		},
+		{
+			name: "get refreshes recency",
+			size: 2,
+			ops:  []op{{set: "a"}, {set: "b"}, {get: "a"}, {set: "c"}},
+			want: []string{"a", "c"},
+		},
+		{
+			name: "set refreshes recency",
+			size: 2,
+			ops:  []op{{set: "a"}, {set: "b"}, {set: "a"}, {set: "c"}},
+			want: []string{"a", "c"},
+		},
+		{
+			name: "unlimited",
+			ops:  []op{{set: "a"}, {set: "b"}, {set: "c"}},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "expired",
+			size: 2,
+			ops:  []op{{set: "a", ttl: -time.Second}, {set: "b"}},
+			want: []string{"b"},
+		},
+		{
+			name: "expired entries are removed",
+			size: 2,
+			ops:  []op{{set: "a"}, {set: "b", ttl: -time.Second}, {get: "b"}, {set: "c"}},
+			want: []string{"a", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory(tt.size)
+			for _, o := range tt.ops {
+				if o.get != "" {
+					if _, _, err := m.Get(o.get); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				ttl := o.ttl
+				if ttl == 0 {
+					ttl = time.Hour
+				}
+				if err := m.Set(o.set, []byte(o.set), ttl); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var got []string
+			for _, k := range []string{"a", "b", "c"} {
+				e, ok, err := m.Get(k)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					continue
+				}
+				if string(e.Value) != k {
+					t.Errorf("%s: got value %q", k, e.Value)
+				}
+				got = append(got, k)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got keys %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryTTL(t *testing.T) {
+	m := NewMemory(0)
+	if err := m.Set("a", []byte("a"), 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := m.Get("a"); !ok {
+		t.Fatal("entry expired before its ttl")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, ok, _ := m.Get("a"); ok {
+		t.Fatal("entry didn't expire after its ttl")
+	}
+}
@@ -2,31 +2,67 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/igolaizola/flyaa"
+	"github.com/igolaizola/flyaa/pkg/aa"
 	"github.com/peterbourgon/ff/v3"
 	"github.com/peterbourgon/ff/v3/ffcli"
 	"github.com/peterbourgon/ff/v3/ffyaml"
 )
 
+// Exit codes returned for each class of error.
+const (
+	ExitError          = 1
+	ExitNoAvailability = 3
+	ExitInvalidMarket  = 4
+	ExitSessionExpired = 5
+	ExitBlocked        = 6
+)
+
+// ExitCode returns the exit code of the process for an error.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, aa.ErrNoAvailability):
+		return ExitNoAvailability
+	case errors.Is(err, aa.ErrInvalidMarket):
+		return ExitInvalidMarket
+	case errors.Is(err, aa.ErrSessionExpired):
+		return ExitSessionExpired
+	case errors.Is(err, aa.ErrBlocked):
+		return ExitBlocked
+	default:
+		return ExitError
+	}
+}
+
 func NewCommand(version, commit, date string) *ffcli.Command {
 	fs := flag.NewFlagSet("flyaa", flag.ExitOnError)
 
 	_ = fs.String("config", "", "config file (optional)")
 	var cfg flyaa.Config
 
-	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
-	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy URL")
-	fs.StringVar(&cfg.BaseURL, "base-url", "", "AA API base URL")
-	fs.StringVar(&cfg.Origin, "origin", "LAX", "origin airport code")
-	fs.StringVar(&cfg.Destination, "destination", "JFK", "destination airport code")
+	addClientFlags(fs, &cfg)
+	addRouteFlags(fs, &cfg)
 	fs.StringVar(&cfg.Date, "date", "2025-12-15", "flight date (YYYY-MM-DD)")
-	fs.IntVar(&cfg.Passengers, "passengers", 1, "number of passengers")
-	fs.StringVar(&cfg.CabinClass, "cabin-class", "main", "cabin class (economy, main, main-plus)")
+	fs.StringVar(&cfg.ReturnDate, "return-date", "", "return flight date for round-trip searches (YYYY-MM-DD, optional)")
+	fs.Var(newStringSlice(&cfg.Legs), "leg", "multi-city leg in ORIGIN-DESTINATION:YYYY-MM-DD format (repeatable, overrides origin, destination and dates)")
+	addPassengerFlags(fs, &cfg)
+	fs.BoolVar(&cfg.AllProducts, "all-products", false, "include the pricing of every fare product of each flight")
+	fs.StringVar(&cfg.Join, "join", "inner", "how cash and award results are combined (inner, left, right, full)")
+	addFilterFlags(fs, &cfg)
+	fs.Var(newStringSlice(&cfg.Sort), "sort", "comma separated sort keys in KEY[:asc|desc] format (cash, points, cpp, duration, departure, arrival)")
+	fs.IntVar(&cfg.Limit, "limit", 0, "maximum number of flights after sorting (0 for no limit)")
+	fs.StringVar(&cfg.Output, "output", "auto", "output format (auto, json, table, csv, ndjson), auto uses a table on terminals and JSON otherwise")
+	fs.StringVar(&cfg.Color, "color", "auto", "color the table output (auto, always, never)")
 
 	return &ffcli.Command{
 		ShortUsage: "flyaa [flags] <subcommand>",
@@ -40,11 +76,236 @@ func NewCommand(version, commit, date string) *ffcli.Command {
 			return flyaa.Run(ctx, &cfg)
 		},
 		Subcommands: []*ffcli.Command{
+			newCalendarCommand(),
+			newWatchCommand(),
+			newHistoryCommand(),
+			newServeCommand(),
+			newFakeServerCommand(),
 			newVersionCommand(version, commit, date),
 		},
 	}
 }
 
+func newCalendarCommand() *ffcli.Command {
+	cmd := "calendar"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+
+	_ = fs.String("config", "", "config file (optional)")
+	var cfg flyaa.CalendarConfig
+
+	addClientFlags(fs, &cfg.Config)
+	addRouteFlags(fs, &cfg.Config)
+	addPassengerFlags(fs, &cfg.Config)
+	fs.StringVar(&cfg.DateFrom, "date-from", "", "first flight date of the range (YYYY-MM-DD)")
+	fs.StringVar(&cfg.DateTo, "date-to", "", "last flight date of the range (YYYY-MM-DD)")
+	fs.StringVar(&cfg.Date, "date", "2025-12-15", "center flight date used when no range is provided (YYYY-MM-DD)")
+	fs.IntVar(&cfg.Days, "days", 3, "number of days before and after the center date")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 3, "number of dates searched concurrently")
+	addFilterFlags(fs, &cfg.Config)
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("flyaa %s [flags]", cmd),
+		ShortHelp:  "search the cheapest fares and awards of a date range",
+		FlagSet:    fs,
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("FLYAA"),
+		},
+		Exec: func(ctx context.Context, args []string) error {
+			return flyaa.Calendar(ctx, &cfg)
+		},
+	}
+}
+
+func newWatchCommand() *ffcli.Command {
+	cmd := "watch"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+
+	_ = fs.String("config", "", "config file (optional)")
+	var cfg flyaa.WatchConfig
+
+	addClientFlags(fs, &cfg.Config)
+	addRouteFlags(fs, &cfg.Config)
+	fs.StringVar(&cfg.Date, "date", "2025-12-15", "flight date (YYYY-MM-DD)")
+	fs.StringVar(&cfg.ReturnDate, "return-date", "", "return flight date for round-trip searches (YYYY-MM-DD, optional)")
+	fs.Var(newStringSlice(&cfg.Legs), "leg", "multi-city leg in ORIGIN-DESTINATION:YYYY-MM-DD format (repeatable, overrides origin, destination and dates)")
+	addPassengerFlags(fs, &cfg.Config)
+	fs.StringVar(&cfg.Join, "join", "inner", "how cash and award results are combined (inner, left, right, full)")
+	fs.DurationVar(&cfg.Interval, "interval", 15*time.Minute, "time between searches")
+	fs.Float64Var(&cfg.MaxCash, "max-cash", 0, "alert when a flight's cash price in USD drops below this value")
+	fs.IntVar(&cfg.MaxPoints, "max-points", 0, "alert when a flight's points drop below this value")
+	fs.Float64Var(&cfg.MinCPP, "min-cpp", 0, "alert when a flight's CPP rises above this value")
+	fs.StringVar(&cfg.Webhook, "webhook", "", "URL where alerts are posted as JSON")
+	fs.StringVar(&cfg.Exec, "exec", "", "shell command run for each alert, with the alert as JSON in its standard input")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("flyaa %s [flags]", cmd),
+		ShortHelp:  "search on an interval and alert when prices match the thresholds",
+		FlagSet:    fs,
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("FLYAA"),
+		},
+		Exec: func(ctx context.Context, args []string) error {
+			return flyaa.Watch(ctx, &cfg)
+		},
+	}
+}
+
+func newHistoryCommand() *ffcli.Command {
+	cmd := "history"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+
+	_ = fs.String("config", "", "config file (optional)")
+	var cfg flyaa.HistoryConfig
+
+	fs.StringVar(&cfg.Store, "store", "", "store where searches are saved (e.g. sqlite:flyaa.db)")
+	fs.StringVar(&cfg.Origin, "origin", "", "origin airport code (optional)")
+	fs.StringVar(&cfg.Destination, "destination", "", "destination airport code (optional)")
+	fs.StringVar(&cfg.Date, "date", "", "flight date (YYYY-MM-DD, optional)")
+	fs.StringVar(&cfg.FlightID, "flight-id", "", "flight ID, such as AA100 or AA200_AA201 (optional)")
+	fs.StringVar(&cfg.CabinClass, "cabin-class", "", "cabin class (optional)")
+	fs.StringVar(&cfg.Since, "since", "", "only searches since this date or RFC3339 time (optional)")
+	fs.StringVar(&cfg.Until, "until", "", "only searches until this date or RFC3339 time (optional)")
+	fs.IntVar(&cfg.Limit, "limit", 1000, "maximum number of observations, keeping the latest ones (0 for no limit)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("flyaa %s [flags]", cmd),
+		ShortHelp:  "print the stored price history of flights",
+		FlagSet:    fs,
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("FLYAA"),
+		},
+		Exec: func(ctx context.Context, args []string) error {
+			return flyaa.History(ctx, &cfg)
+		},
+	}
+}
+
+func newServeCommand() *ffcli.Command {
+	cmd := "serve"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+
+	_ = fs.String("config", "", "config file (optional)")
+	var cfg flyaa.ServeConfig
+
+	addClientFlags(fs, &cfg.Config)
+	addRouteFlags(fs, &cfg.Config)
+	addPassengerFlags(fs, &cfg.Config)
+	fs.StringVar(&cfg.Date, "date", "2025-12-15", "default flight date (YYYY-MM-DD)")
+	fs.StringVar(&cfg.Join, "join", "inner", "default join of cash and award results (inner, left, right, full)")
+	fs.StringVar(&cfg.Addr, "addr", "127.0.0.1:8080", "address to listen on")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("flyaa %s [flags]", cmd),
+		ShortHelp:  "serve the search as a REST API",
+		FlagSet:    fs,
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("FLYAA"),
+		},
+		Exec: func(ctx context.Context, args []string) error {
+			return flyaa.Serve(ctx, &cfg)
+		},
+	}
+}
+
+func newFakeServerCommand() *ffcli.Command {
+	cmd := "fake-server"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+
+	_ = fs.String("config", "", "config file (optional)")
+	var cfg flyaa.FakeServerConfig
+
+	fs.StringVar(&cfg.Addr, "addr", "127.0.0.1:8765", "address to listen on")
+	fs.StringVar(&cfg.Scenario, "scenario", "", "scenario JSON file or name of a built-in scenario (default, errors, flaky)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("flyaa %s [flags]", cmd),
+		ShortHelp:  "serve a fake AA search API for tests and demos",
+		FlagSet:    fs,
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("FLYAA"),
+		},
+		Exec: func(ctx context.Context, args []string) error {
+			return flyaa.FakeServer(ctx, &cfg)
+		},
+	}
+}
+
+func addClientFlags(fs *flag.FlagSet, cfg *flyaa.Config) {
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.Var(newStringSlice(&cfg.Proxies), "proxy", "proxy URL (repeatable)")
+	fs.StringVar(&cfg.ProxyFile, "proxy-file", "", "file with a proxy URL per line")
+	fs.StringVar(&cfg.ProxySelection, "proxy-selection", aa.ProxyRoundRobin, "proxy selection strategy (round-robin, random)")
+	fs.DurationVar(&cfg.ProxyQuarantine, "proxy-quarantine", time.Minute, "time a proxy isn't used after being blocked, rate limited or failing to connect")
+	fs.StringVar(&cfg.BaseURL, "base-url", "", "AA API base URL")
+	fs.StringVar(&cfg.Record, "record", "", "directory where request and response pairs are recorded")
+	fs.StringVar(&cfg.Replay, "replay", "", "directory with recorded responses served instead of calling the AA API")
+	fs.BoolVar(&cfg.FreshClient, "fresh-client", false, "create a new TLS client for each request instead of reusing connections")
+	fs.Float64Var(&cfg.RateLimit, "rate-limit", 0, "maximum requests per second to the AA API (0 for no limit)")
+	fs.IntVar(&cfg.RateBurst, "rate-burst", 1, "maximum burst of requests allowed by the rate limit")
+	fs.IntVar(&cfg.MaxInFlight, "max-in-flight", 0, "maximum concurrent requests to the AA API (0 for no limit)")
+	fs.IntVar(&cfg.MaxFollowUps, "max-follow-ups", 10, "maximum options of each round-trip or multi-city slice whose next slices are searched, cheapest first (-1 for all)")
+	fs.IntVar(&cfg.MaxFollowUpRequests, "max-follow-up-requests", 100, "maximum follow-up requests of each round-trip or multi-city search across all its slices (-1 for no limit)")
+	fs.StringVar(&cfg.Cache, "cache", "memory", "cache of search results (memory, disk)")
+	fs.StringVar(&cfg.CacheDir, "cache-dir", "", "directory of the disk cache (default user cache directory)")
+	fs.DurationVar(&cfg.CacheTTL, "cache-ttl", 10*time.Minute, "time search results are cached")
+	fs.IntVar(&cfg.CacheSize, "cache-size", 1000, "maximum number of searches in the memory cache")
+	fs.BoolVar(&cfg.NoCache, "no-cache", false, "disable the cache of search results")
+	fs.BoolVar(&cfg.Refresh, "refresh", false, "ignore cached results, storing the new ones")
+	fs.StringVar(&cfg.Store, "store", "", "store where searches are saved (e.g. sqlite:flyaa.db)")
+
+	retry := aa.DefaultRetryPolicy()
+	cfg.Retry = retry
+	fs.IntVar(&cfg.Retry.MaxAttempts, "retry-max-attempts", retry.MaxAttempts, "maximum number of attempts per request")
+	fs.DurationVar(&cfg.Retry.BaseDelay, "retry-base-delay", retry.BaseDelay, "wait before the first retry, doubled on each retry")
+	fs.DurationVar(&cfg.Retry.MaxDelay, "retry-max-delay", retry.MaxDelay, "maximum wait between retries")
+	fs.Float64Var(&cfg.Retry.Jitter, "retry-jitter", retry.Jitter, "random fraction (0-1) added or subtracted to retry waits (0 to disable)")
+	fs.DurationVar(&cfg.Retry.RetryAfterLimit, "retry-after-limit", retry.RetryAfterLimit, "maximum Retry-After wait, longer ones fail without retrying")
+	fs.Var(newIntSlice(&cfg.Retry.RetryStatus), "retry-status", "comma separated HTTP status codes that are retried")
+}
+
+func addRouteFlags(fs *flag.FlagSet, cfg *flyaa.Config) {
+	fs.StringVar(&cfg.Origin, "origin", "LAX", "origin airport code")
+	fs.StringVar(&cfg.Destination, "destination", "JFK", "destination airport code")
+}
+
+func addPassengerFlags(fs *flag.FlagSet, cfg *flyaa.Config) {
+	fs.IntVar(&cfg.Passengers, "passengers", 1, "number of adult passengers, ignored if any passenger type is set")
+	fs.IntVar(&cfg.Adults, "adults", 0, "number of adults")
+	fs.IntVar(&cfg.Children, "children", 0, "number of children")
+	fs.IntVar(&cfg.InfantsLap, "infants-lap", 0, "number of infants in lap")
+	fs.IntVar(&cfg.InfantsSeat, "infants-seat", 0, "number of infants in seat")
+	fs.IntVar(&cfg.Seniors, "seniors", 0, "number of seniors")
+	fs.StringVar(&cfg.CabinClass, "cabin-class", "main", "cabin class (economy, main, main-plus, premium-economy, premium-economy-flexible, business, business-flexible, first, first-flexible)")
+}
+
+func addFilterFlags(fs *flag.FlagSet, cfg *flyaa.Config) {
+	f := &cfg.Filter
+	fs.BoolVar(&f.Nonstop, "nonstop", false, "only nonstop flights")
+	fs.Var(newOptionalInt(&f.MaxStops), "max-stops", "maximum number of stops of each leg")
+	fs.StringVar(&f.DepartAfter, "depart-after", "", "only flights departing at or after this local time (HH:MM)")
+	fs.StringVar(&f.DepartBefore, "depart-before", "", "only flights departing at or before this local time (HH:MM)")
+	fs.StringVar(&f.ArriveBefore, "arrive-before", "", "only flights arriving at or before this local time on the departure day (HH:MM)")
+	fs.DurationVar(&f.MaxDuration, "max-duration", 0, "maximum duration of each leg (e.g. 6h30m)")
+	fs.Float64Var(&f.MaxCash, "max-cash", 0, "maximum cash price per passenger in USD")
+	fs.IntVar(&f.MaxPoints, "max-points", 0, "maximum points per passenger")
+	fs.Float64Var(&f.MinCPP, "min-cpp", 0, "minimum CPP")
+}
+
 func newVersionCommand(version, commit, date string) *ffcli.Command {
 	return &ffcli.Command{
 		Name:       "version",
@@ -72,3 +333,91 @@ func newVersionCommand(version, commit, date string) *ffcli.Command {
 		},
 	}
 }
+
+// intSlice is a flag value with a comma separated list of integers. The first
+// occurrence of the flag replaces the default values.
+type intSlice struct {
+	values *[]int
+	set    bool
+}
+
+func newIntSlice(values *[]int) *intSlice {
+	return &intSlice{values: values}
+}
+
+func (s *intSlice) String() string {
+	if s.values == nil {
+		return ""
+	}
+	var strs []string
+	for _, v := range *s.values {
+		strs = append(strs, strconv.Itoa(v))
+	}
+	return strings.Join(strs, ",")
+}
+
+func (s *intSlice) Set(v string) error {
+	if !s.set {
+		*s.values = []int{}
+		s.set = true
+	}
+	for _, f := range strings.Split(v, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q: %w", f, err)
+		}
+		*s.values = append(*s.values, n)
+	}
+	return nil
+}
+
+// optionalInt is a flag value with an integer that is nil until the flag is
+// set.
+type optionalInt struct {
+	value **int
+}
+
+func newOptionalInt(value **int) *optionalInt {
+	return &optionalInt{value: value}
+}
+
+func (o *optionalInt) String() string {
+	if o.value == nil || *o.value == nil {
+		return ""
+	}
+	return strconv.Itoa(**o.value)
+}
+
+func (o *optionalInt) Set(v string) error {
+	i, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", v)
+	}
+	*o.value = &i
+	return nil
+}
+
+// stringSlice is a flag value that appends every occurrence of the flag.
+type stringSlice struct {
+	values *[]string
+}
+
+func newStringSlice(values *[]string) *stringSlice {
+	return &stringSlice{values: values}
+}
+
+func (s *stringSlice) String() string {
+	if s.values == nil {
+		return ""
+	}
+	return strings.Join(*s.values, ",")
+}
+
+func (s *stringSlice) Set(v string) error {
+	*s.values = append(*s.values, v)
+	return nil
+}
@@ -0,0 +1,166 @@
+package fakeaa
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+//go:embed scenarios/*.json
+var scenarios embed.FS
+
+// Scenario describes the responses of the fake server.
+type Scenario struct {
+	// Delay is added to every response.
+	Delay Duration `json:"delay,omitempty"`
+	// Bursts are failures returned to the first requests received by the
+	// server, in order.
+	Bursts []Burst `json:"bursts,omitempty"`
+	// Routes are the responses of each route. The first route matching the
+	// requested slice is used.
+	Routes []Route `json:"routes"`
+}
+
+// Burst fails a number of consecutive requests with a status code.
+type Burst struct {
+	Status int `json:"status"`
+	// Count is the number of requests that fail. Zero or negative values
+	// fail every request.
+	Count int `json:"count"`
+	// RetryAfter is the optional value of the Retry-After header.
+	RetryAfter string `json:"retry_after,omitempty"`
+}
+
+// Route is the response of the searches of a route.
+type Route struct {
+	// Origin and Destination of the slice. Empty values match any airport.
+	Origin      string `json:"origin,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	// SearchType is either revenue or award. Empty values match both.
+	SearchType string `json:"search_type,omitempty"`
+	// Delay is added to the responses of the route.
+	Delay Duration `json:"delay,omitempty"`
+	// Status is an optional HTTP status code returned instead of the flights.
+	Status int `json:"status,omitempty"`
+	// Error is returned in the error field of the response, with any shape
+	// used by the API (string, object or list).
+	Error   any      `json:"error,omitempty"`
+	Flights []Flight `json:"flights,omitempty"`
+}
+
+// Flight is one of the options of a slice.
+type Flight struct {
+	Segments []Segment `json:"segments"`
+	// Duration is the total duration in minutes.
+	Duration int     `json:"duration"`
+	Prices   []Price `json:"prices"`
+}
+
+// Segment is a single flight of an option.
+type Segment struct {
+	Carrier      string `json:"carrier,omitempty"`
+	CarrierName  string `json:"carrier_name,omitempty"`
+	FlightNumber string `json:"flight_number"`
+	// Origin and Destination default to the ones of the requested slice for
+	// the first and the last segments.
+	Origin      string `json:"origin,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	// Departure and Arrival are local times with UTC offset (e.g.
+	// "08:00-08:00") on the requested date plus the day offsets.
+	Departure    string `json:"departure"`
+	Arrival      string `json:"arrival"`
+	DepartureDay int    `json:"departure_day,omitempty"`
+	ArrivalDay   int    `json:"arrival_day,omitempty"`
+}
+
+// Price is the per passenger pricing of a fare product. Revenue responses
+// include the products with cash price and award responses the products with
+// points.
+type Price struct {
+	ProductType string  `json:"product_type"`
+	Cash        float64 `json:"cash,omitempty"`
+	Points      int     `json:"points,omitempty"`
+	Taxes       float64 `json:"taxes,omitempty"`
+}
+
+// Duration is a time.Duration encoded as a string (e.g. "500ms").
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("fakeaa: duration must be a string: %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("fakeaa: invalid duration %q: %w", s, err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// DefaultScenario returns the scenario used when none is provided: a nonstop
+// and a one-stop flight for any route, priced in several cabins.
+func DefaultScenario() *Scenario {
+	s, err := parseScenario(mustReadScenario("default"))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// LoadScenario loads a scenario from a JSON file. Names of the embedded
+// scenarios (default, errors, flaky) can be used instead of a path.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !strings.ContainsAny(path, `/\.`) {
+		data, err = scenarios.ReadFile(fmt.Sprintf("scenarios/%s.json", path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fakeaa: couldn't read scenario: %w", err)
+	}
+	return parseScenario(data)
+}
+
+func mustReadScenario(name string) []byte {
+	data, err := scenarios.ReadFile(fmt.Sprintf("scenarios/%s.json", name))
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func parseScenario(data []byte) (*Scenario, error) {
+	var s Scenario
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("fakeaa: couldn't parse scenario: %w", err)
+	}
+	for i, r := range s.Routes {
+		switch r.SearchType {
+		case "", searchRevenue, searchAward:
+		default:
+			return nil, fmt.Errorf("fakeaa: route %d: invalid search type %q", i, r.SearchType)
+		}
+		for j, f := range r.Flights {
+			if len(f.Segments) == 0 {
+				return nil, fmt.Errorf("fakeaa: route %d: flight %d has no segments", i, j)
+			}
+			for _, sg := range f.Segments {
+				if _, err := time.Parse(clockLayout, sg.Departure); err != nil {
+					return nil, fmt.Errorf("fakeaa: route %d: flight %d: invalid departure %q", i, j, sg.Departure)
+				}
+				if _, err := time.Parse(clockLayout, sg.Arrival); err != nil {
+					return nil, fmt.Errorf("fakeaa: route %d: flight %d: invalid arrival %q", i, j, sg.Arrival)
+				}
+			}
+		}
+	}
+	return &s, nil
+}
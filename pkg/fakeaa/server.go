@@ -0,0 +1,463 @@
+// Package fakeaa implements a fake AA search API that serves the responses of
+// a scenario, so the client can be exercised without hitting AA.
+package fakeaa
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Search types of the requests.
+const (
+	searchRevenue = "revenue"
+	searchAward   = "award"
+)
+
+// passengerInfantLap is the passenger type of infants in lap.
+const passengerInfantLap = "infantInLap"
+
+// clockLayout is the layout of the segment times of a scenario.
+const clockLayout = "15:04Z07:00"
+
+// Server is an http.Handler that implements the AA search endpoint.
+type Server struct {
+	scenario *Scenario
+
+	lck      sync.Mutex
+	requests int
+}
+
+// NewServer creates a server for the scenario. The default scenario is used
+// if it is nil.
+func NewServer(s *Scenario) *Server {
+	if s == nil {
+		s = DefaultScenario()
+	}
+	return &Server{scenario: s}
+}
+
+// Requests returns the number of requests received.
+func (s *Server) Requests() int {
+	s.lck.Lock()
+	defer s.lck.Unlock()
+	return s.requests
+}
+
+type searchRequest struct {
+	Passengers []struct {
+		Type  string `json:"type"`
+		Count int    `json:"count"`
+	} `json:"passengers"`
+	QueryParams struct {
+		SliceIndex int    `json:"sliceIndex"`
+		SessionID  string `json:"sessionId"`
+		SolutionID string `json:"solutionId"`
+	} `json:"queryParams"`
+	Slices []struct {
+		DepartureDate string `json:"departureDate"`
+		Destination   string `json:"destination"`
+		Origin        string `json:"origin"`
+	} `json:"slices"`
+	TripOptions struct {
+		SearchType string `json:"searchType"`
+	} `json:"tripOptions"`
+}
+
+type searchResponse struct {
+	ResponseMetadata struct {
+		SessionID   string `json:"sessionId"`
+		SolutionSet string `json:"solutionSet"`
+	} `json:"responseMetadata"`
+	Error  any             `json:"error"`
+	Slices []responseSlice `json:"slices"`
+}
+
+type responseSlice struct {
+	Segments          []responseSegment `json:"segments"`
+	CheapestPrice     *pricingDetail    `json:"cheapestPrice,omitempty"`
+	PricingDetail     []pricingDetail   `json:"pricingDetail"`
+	Stops             int               `json:"stops"`
+	DurationInMinutes int               `json:"durationInMinutes"`
+}
+
+type responseSegment struct {
+	Flight struct {
+		CarrierCode  string `json:"carrierCode"`
+		CarrierName  string `json:"carrierName"`
+		FlightNumber string `json:"flightNumber"`
+	} `json:"flight"`
+	DepartureDateTime string           `json:"departureDateTime"`
+	ArrivalDateTime   string           `json:"arrivalDateTime"`
+	Origin            responseLocation `json:"origin"`
+	Destination       responseLocation `json:"destination"`
+}
+
+type responseLocation struct {
+	Code     string `json:"code"`
+	CityName string `json:"cityName"`
+}
+
+type pricingDetail struct {
+	PerPassengerPrice        string           `json:"perPassengerPrice"`
+	AllPassengerTaxesAndFees amount           `json:"allPassengerTaxesAndFees"`
+	ProductType              string           `json:"productType"`
+	SolutionID               string           `json:"solutionID"`
+	PassengerPricing         []passengerPrice `json:"passengerPricing,omitempty"`
+}
+
+type passengerPrice struct {
+	PassengerType            string `json:"passengerType"`
+	Count                    int    `json:"count"`
+	PerPassengerPrice        string `json:"perPassengerPrice"`
+	AllPassengerTaxesAndFees amount `json:"allPassengerTaxesAndFees"`
+}
+
+type amount struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/search/itinerary/v2.0") {
+		http.NotFound(w, r)
+		return
+	}
+	s.lck.Lock()
+	s.requests++
+	n := s.requests
+	s.lck.Unlock()
+	wait(r, time.Duration(s.scenario.Delay))
+
+	// Fail the request if it is part of a burst
+	if b, ok := s.burst(n); ok {
+		if b.RetryAfter != "" {
+			w.Header().Set("Retry-After", b.RetryAfter)
+		}
+		slog.Debug("fakeaa: burst", "request", n, "status", b.Status)
+		http.Error(w, http.StatusText(b.Status), b.Status)
+		return
+	}
+
+	// Parse request
+	var req searchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	idx := req.QueryParams.SliceIndex
+	if idx < 0 || idx >= len(req.Slices) {
+		http.Error(w, fmt.Sprintf("invalid slice index %d", idx), http.StatusBadRequest)
+		return
+	}
+	slice := req.Slices[idx]
+	searchType := req.TripOptions.SearchType
+	slog.Debug("fakeaa: search", "request", n, "type", searchType, "origin", slice.Origin, "destination", slice.Destination, "date", slice.DepartureDate, "slice", idx)
+
+	// Find the route of the slice
+	var resp searchResponse
+	resp.ResponseMetadata.SessionID = "fake-session"
+	if req.QueryParams.SessionID != "" {
+		resp.ResponseMetadata.SessionID = req.QueryParams.SessionID
+	}
+	resp.ResponseMetadata.SolutionSet = fmt.Sprintf("fake-set-%d", idx)
+	resp.Slices = []responseSlice{}
+	route, ok := s.route(slice.Origin, slice.Destination, searchType)
+	if !ok {
+		resp.Error = map[string]any{"code": "NO_FLIGHTS_FOUND", "message": "No flights found"}
+		writeJSON(w, resp)
+		return
+	}
+	wait(r, time.Duration(route.Delay))
+	if route.Status != 0 {
+		http.Error(w, http.StatusText(route.Status), route.Status)
+		return
+	}
+	if route.Error != nil {
+		resp.Error = route.Error
+		writeJSON(w, resp)
+		return
+	}
+
+	// Follow-up slices are priced as the whole trip, so the price of the
+	// solutions selected in the previous slices is added to each option
+	var selected []selection
+	var prior Price
+	if idx > 0 {
+		var ok bool
+		selected, ok = parseSolutionID(req.QueryParams.SolutionID, searchType)
+		if ok && len(selected) == idx {
+			prior, ok = s.selectedPrice(req, searchType, selected)
+		}
+		if !ok || len(selected) != idx {
+			http.Error(w, fmt.Sprintf("invalid solution id %q", req.QueryParams.SolutionID), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Build the options of the slice
+	date, err := time.Parse("2006-01-02", slice.DepartureDate)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid departure date %q", slice.DepartureDate), http.StatusBadRequest)
+		return
+	}
+	for i, f := range route.Flights {
+		rs := responseSlice{
+			Stops:             len(f.Segments) - 1,
+			DurationInMinutes: f.Duration,
+			PricingDetail:     []pricingDetail{},
+		}
+		for j, sg := range f.Segments {
+			rs.Segments = append(rs.Segments, newSegment(sg, j, len(f.Segments), slice.Origin, slice.Destination, date))
+		}
+		var cheapest float64
+		for _, p := range f.Prices {
+			pd, ok := newPricingDetail(p, prior, req, searchType, solutionID(searchType, selected, i, p.ProductType))
+			if !ok {
+				continue
+			}
+			rs.PricingDetail = append(rs.PricingDetail, pd)
+			price := p.Cash
+			if searchType == searchAward {
+				price = float64(p.Points)
+			}
+			if rs.CheapestPrice == nil || price < cheapest {
+				rs.CheapestPrice = &pd
+				cheapest = price
+			}
+		}
+		if rs.CheapestPrice == nil {
+			continue
+		}
+		resp.Slices = append(resp.Slices, rs)
+	}
+	if len(resp.Slices) == 0 {
+		resp.Error = map[string]any{"code": "NO_FLIGHTS_FOUND", "message": "No flights found"}
+	}
+	writeJSON(w, resp)
+}
+
+// burst returns the burst of the nth request, if any.
+func (s *Server) burst(n int) (Burst, bool) {
+	for _, b := range s.scenario.Bursts {
+		if b.Count <= 0 {
+			return b, true
+		}
+		if n <= b.Count {
+			return b, true
+		}
+		n -= b.Count
+	}
+	return Burst{}, false
+}
+
+// route returns the first route of the scenario matching the slice.
+func (s *Server) route(origin, destination, searchType string) (Route, bool) {
+	for _, r := range s.scenario.Routes {
+		if r.Origin != "" && !strings.EqualFold(r.Origin, origin) {
+			continue
+		}
+		if r.Destination != "" && !strings.EqualFold(r.Destination, destination) {
+			continue
+		}
+		if r.SearchType != "" && r.SearchType != searchType {
+			continue
+		}
+		return r, true
+	}
+	return Route{}, false
+}
+
+// selection is the flight and the product of a solution selected in a slice.
+type selection struct {
+	flight  int
+	product string
+}
+
+// solutionID returns the ID of a solution, which encodes the selections of
+// every slice up to this one (e.g. "award-0.COACH-1.COACH").
+func solutionID(searchType string, selected []selection, flight int, product string) string {
+	id := searchType
+	for _, sel := range append(selected, selection{flight: flight, product: product}) {
+		id += fmt.Sprintf("-%d.%s", sel.flight, sel.product)
+	}
+	return id
+}
+
+// parseSolutionID returns the selections of a solution ID.
+func parseSolutionID(id, searchType string) ([]selection, bool) {
+	parts := strings.Split(id, "-")
+	if len(parts) < 2 || parts[0] != searchType {
+		return nil, false
+	}
+	var selected []selection
+	for _, part := range parts[1:] {
+		flight, product, ok := strings.Cut(part, ".")
+		if !ok {
+			return nil, false
+		}
+		n, err := strconv.Atoi(flight)
+		if err != nil {
+			return nil, false
+		}
+		selected = append(selected, selection{flight: n, product: product})
+	}
+	return selected, true
+}
+
+// selectedPrice returns the sum of the prices of the selected solutions of
+// the previous slices.
+func (s *Server) selectedPrice(req searchRequest, searchType string, selected []selection) (Price, bool) {
+	var total Price
+	for k, sel := range selected {
+		route, ok := s.route(req.Slices[k].Origin, req.Slices[k].Destination, searchType)
+		if !ok || sel.flight < 0 || sel.flight >= len(route.Flights) {
+			return Price{}, false
+		}
+		i := slices.IndexFunc(route.Flights[sel.flight].Prices, func(p Price) bool {
+			return p.ProductType == sel.product
+		})
+		if i < 0 {
+			return Price{}, false
+		}
+		p := route.Flights[sel.flight].Prices[i]
+		total.Cash += p.Cash
+		total.Points += p.Points
+		total.Taxes += p.Taxes
+	}
+	return total, true
+}
+
+func newSegment(sg Segment, i, n int, origin, destination string, date time.Time) responseSegment {
+	var rs responseSegment
+	rs.Flight.CarrierCode = sg.Carrier
+	if rs.Flight.CarrierCode == "" {
+		rs.Flight.CarrierCode = "AA"
+	}
+	rs.Flight.CarrierName = sg.CarrierName
+	if rs.Flight.CarrierName == "" && rs.Flight.CarrierCode == "AA" {
+		rs.Flight.CarrierName = "American Airlines"
+	}
+	rs.Flight.FlightNumber = sg.FlightNumber
+	rs.Origin.Code = sg.Origin
+	if rs.Origin.Code == "" && i == 0 {
+		rs.Origin.Code = origin
+	}
+	rs.Destination.Code = sg.Destination
+	if rs.Destination.Code == "" && i == n-1 {
+		rs.Destination.Code = destination
+	}
+	rs.Origin.CityName = rs.Origin.Code
+	rs.Destination.CityName = rs.Destination.Code
+	rs.DepartureDateTime = dateTime(date, sg.Departure, sg.DepartureDay)
+	rs.ArrivalDateTime = dateTime(date, sg.Arrival, sg.ArrivalDay)
+	return rs
+}
+
+// dateTime returns the local time of a clock time on the date plus the
+// given days, in the format used by the API.
+func dateTime(date time.Time, clock string, days int) string {
+	t, err := time.Parse(clockLayout, clock)
+	if err != nil {
+		return ""
+	}
+	d := date.AddDate(0, 0, days)
+	t = time.Date(d.Year(), d.Month(), d.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+	return t.Format("2006-01-02T15:04:05.000-07:00")
+}
+
+// newPricingDetail returns the pricing of a product plus the prior price of
+// the previous slices for the passengers of the request. It returns false if
+// the product isn't priced in the search type.
+func newPricingDetail(p, prior Price, req searchRequest, searchType, solutionID string) (pricingDetail, bool) {
+	pd := pricingDetail{
+		ProductType: p.ProductType,
+		SolutionID:  solutionID,
+	}
+	award := searchType == searchAward
+	if (award && p.Points <= 0) || (!award && p.Cash <= 0) {
+		return pricingDetail{}, false
+	}
+	p.Cash += prior.Cash
+	p.Points += prior.Points
+	p.Taxes += prior.Taxes
+	perPassenger := p.Taxes
+	pd.PerPassengerPrice = formatPoints(p.Points)
+	if !award {
+		perPassenger = p.Cash
+		pd.PerPassengerPrice = fmt.Sprintf("$%s", strconv.FormatFloat(p.Cash, 'f', -1, 64))
+	}
+	var total float64
+	var types int
+	for _, pax := range req.Passengers {
+		if pax.Count <= 0 {
+			continue
+		}
+		types++
+		price := pd.PerPassengerPrice
+		a := round(perPassenger * float64(pax.Count))
+		if pax.Type == passengerInfantLap {
+			// Infants in lap fly for free and don't redeem points
+			price = "0"
+			if !award {
+				price = "$0"
+			}
+			a = 0
+		}
+		total += a
+		pd.PassengerPricing = append(pd.PassengerPricing, passengerPrice{
+			PassengerType:            pax.Type,
+			Count:                    pax.Count,
+			PerPassengerPrice:        price,
+			AllPassengerTaxesAndFees: amount{Amount: a, Currency: "USD"},
+		})
+	}
+	if types < 2 {
+		// The API only breaks down the pricing of mixed parties
+		pd.PassengerPricing = nil
+	}
+	pd.AllPassengerTaxesAndFees = amount{Amount: round(total), Currency: "USD"}
+	return pd, true
+}
+
+// formatPoints formats points the way the API does (e.g. "12.5K").
+func formatPoints(points int) string {
+	if points < 1000 {
+		return strconv.Itoa(points)
+	}
+	return strconv.FormatFloat(float64(points)/1000, 'f', -1, 64) + "K"
+}
+
+func round(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+// wait sleeps for the given duration unless the request is canceled.
+func wait(r *http.Request, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-r.Context().Done():
+	case <-t.C:
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
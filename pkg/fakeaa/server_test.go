@@ -0,0 +1,99 @@
+package fakeaa
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerFollowUpPricing(t *testing.T) {
+	srv := httptest.NewServer(NewServer(nil))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		searchType string
+		sliceIndex int
+		solutionID string
+		// want is the price of each product of the first option
+		want       map[string]string
+		wantStatus int
+	}{
+		{
+			name:       "first slice revenue",
+			searchType: searchRevenue,
+			want:       map[string]string{"COACH": "$199", "BUSINESS": "$899"},
+		},
+		{
+			name:       "follow-up revenue adds the selected solution",
+			searchType: searchRevenue,
+			sliceIndex: 1,
+			solutionID: "revenue-1.COACH",
+			want:       map[string]string{"COACH": "$368", "BUSINESS": "$1068"},
+		},
+		{
+			name:       "follow-up award adds the selected solution",
+			searchType: searchAward,
+			sliceIndex: 1,
+			solutionID: "award-2.COACH",
+			want:       map[string]string{"COACH": "20K", "BUSINESS": "65K"},
+		},
+		{
+			name:       "unknown solution",
+			searchType: searchAward,
+			sliceIndex: 1,
+			solutionID: "award-9.COACH",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := map[string]any{
+				"passengers":  []map[string]any{{"type": "adult", "count": 1}},
+				"queryParams": map[string]any{"sliceIndex": tt.sliceIndex, "solutionId": tt.solutionID},
+				"slices": []map[string]any{
+					{"origin": "LAX", "destination": "JFK", "departureDate": "2025-12-15"},
+					{"origin": "JFK", "destination": "LAX", "departureDate": "2025-12-20"},
+				},
+				"tripOptions": map[string]any{"searchType": tt.searchType},
+			}
+			body, err := json.Marshal(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.Post(srv.URL+"/search/itinerary/v2.0", "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			wantStatus := tt.wantStatus
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+			if resp.StatusCode != wantStatus {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, wantStatus)
+			}
+			if wantStatus != http.StatusOK {
+				return
+			}
+			var out searchResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatal(err)
+			}
+			if len(out.Slices) == 0 {
+				t.Fatal("no slices")
+			}
+			got := map[string]string{}
+			for _, pd := range out.Slices[0].PricingDetail {
+				got[pd.ProductType] = pd.PerPassengerPrice
+			}
+			for product, want := range tt.want {
+				if got[product] != want {
+					t.Errorf("%s: got %q, want %q", product, got[product], want)
+				}
+			}
+		})
+	}
+}
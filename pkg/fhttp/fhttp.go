@@ -2,21 +2,33 @@ package fhttp
 
 import (
 	"fmt"
+	stdhttp "net/http"
 	"time"
 
+	http "github.com/bogdanfinn/fhttp"
 	tlsclient "github.com/bogdanfinn/tls-client"
 	"github.com/bogdanfinn/tls-client/profiles"
 )
 
+// Client is a TLS fingerprinting HTTP client. It also implements the standard
+// library round tripper, so it can be used as the transport of a net/http
+// client.
 type Client interface {
 	tlsclient.HttpClient
+	stdhttp.RoundTripper
 }
 
 type client struct {
 	tlsclient.HttpClient
 }
 
+// NewClient creates a client with the Okhttp4Android13 profile.
 func NewClient(timeout time.Duration, useJar bool, proxy string, debug bool) (Client, error) {
+	return NewClientWithProfile(profiles.Okhttp4Android13, timeout, useJar, proxy, debug)
+}
+
+// NewClientWithProfile creates a client with the given TLS fingerprint profile.
+func NewClientWithProfile(profile profiles.ClientProfile, timeout time.Duration, useJar bool, proxy string, debug bool) (Client, error) {
 	jar := tlsclient.NewCookieJar()
 	secs := int(timeout.Seconds())
 	if secs <= 0 {
@@ -24,7 +36,7 @@ func NewClient(timeout time.Duration, useJar bool, proxy string, debug bool) (Cl
 	}
 	options := []tlsclient.HttpClientOption{
 		tlsclient.WithTimeoutSeconds(secs),
-		tlsclient.WithClientProfile(profiles.Okhttp4Android13),
+		tlsclient.WithClientProfile(profile),
 		tlsclient.WithInsecureSkipVerify(),
 	}
 	if useJar {
@@ -43,3 +55,37 @@ func NewClient(timeout time.Duration, useJar bool, proxy string, debug bool) (Cl
 	}
 	return &client{HttpClient: c}, nil
 }
+
+// RoundTrip sends a standard library request with the TLS client and converts
+// the response back to the standard library types. Like any round tripper, it
+// closes the request body, even on errors.
+func (c *client) RoundTrip(req *stdhttp.Request) (*stdhttp.Response, error) {
+	sent := false
+	defer func() {
+		if !sent && req.Body != nil {
+			_ = req.Body.Close()
+		}
+	}()
+	r, err := http.NewRequestWithContext(req.Context(), req.Method, req.URL.String(), req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("fhttp: couldn't create request: %w", err)
+	}
+	r.Header = http.Header(req.Header.Clone())
+	r.ContentLength = req.ContentLength
+	resp, err := c.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	sent = true
+	return &stdhttp.Response{
+		Status:        resp.Status,
+		StatusCode:    resp.StatusCode,
+		Proto:         resp.Proto,
+		ProtoMajor:    resp.ProtoMajor,
+		ProtoMinor:    resp.ProtoMinor,
+		Header:        stdhttp.Header(resp.Header),
+		Body:          resp.Body,
+		ContentLength: resp.ContentLength,
+		Request:       req,
+	}, nil
+}
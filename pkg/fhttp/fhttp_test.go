@@ -0,0 +1,52 @@
+package fhttp
+
+import (
+	"context"
+	"io"
+	stdhttp "net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type closeBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestRoundTripClosesBodyOnError(t *testing.T) {
+	c, err := NewClient(time.Second, false, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		method string
+	}{
+		{name: "invalid request", method: "BAD METHOD"},
+		{name: "connection error", method: "POST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Nothing listens on port 1 of localhost
+			u, err := url.Parse("http://127.0.0.1:1/search")
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := &closeBody{Reader: strings.NewReader("{}")}
+			req := (&stdhttp.Request{Method: tt.method, URL: u, Header: stdhttp.Header{}, Body: body}).WithContext(context.Background())
+			if _, err := c.RoundTrip(req); err == nil {
+				t.Fatal("expected error")
+			}
+			if !body.closed {
+				t.Error("request body wasn't closed")
+			}
+		})
+	}
+}
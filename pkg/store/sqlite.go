@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// schema creates the tables of the store. Flights repeat the route, date and
+// time of their search so trends can be queried without joins.
+const schema = `
+CREATE TABLE IF NOT EXISTS searches (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at  TIMESTAMP NOT NULL,
+	source      TEXT NOT NULL,
+	trip_type   TEXT NOT NULL,
+	origin      TEXT NOT NULL,
+	destination TEXT NOT NULL,
+	date        TEXT NOT NULL,
+	return_date TEXT NOT NULL DEFAULT '',
+	passengers  INTEGER NOT NULL,
+	cabin_class TEXT NOT NULL,
+	flights     INTEGER NOT NULL,
+	metadata    TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS flights (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	search_id       INTEGER NOT NULL REFERENCES searches(id) ON DELETE CASCADE,
+	created_at      TIMESTAMP NOT NULL,
+	origin          TEXT NOT NULL,
+	destination     TEXT NOT NULL,
+	date            TEXT NOT NULL,
+	cabin_class     TEXT NOT NULL,
+	flight_id       TEXT NOT NULL,
+	is_nonstop      INTEGER NOT NULL,
+	cash_price_usd  REAL,
+	points_required INTEGER,
+	taxes_fees_usd  REAL,
+	cpp             REAL,
+	data            TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS flights_route ON flights (origin, destination, date, created_at);
+CREATE INDEX IF NOT EXISTS flights_flight_id ON flights (flight_id, created_at);
+CREATE INDEX IF NOT EXISTS searches_route ON searches (origin, destination, date, created_at);
+`
+
+// SQLite is a store backed by a SQLite database.
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens or creates a SQLite database at the path.
+func OpenSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)", path))
+	if err != nil {
+		return nil, fmt.Errorf("store: couldn't open sqlite database: %w", err)
+	}
+	// SQLite allows a single writer
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("store: couldn't create sqlite schema: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLite) SaveSearch(ctx context.Context, search *Search) (int64, error) {
+	metadata, err := json.Marshal(search.Metadata)
+	if err != nil {
+		return 0, fmt.Errorf("store: couldn't marshal search metadata: %w", err)
+	}
+	created := search.Time.UTC()
+	if search.Time.IsZero() {
+		created = time.Now().UTC()
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("store: couldn't begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO searches
+		(created_at, source, trip_type, origin, destination, date, return_date, passengers, cabin_class, flights, metadata)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		created, search.Source, search.TripType, search.Origin, search.Destination, search.Date,
+		search.ReturnDate, search.Passengers, search.CabinClass, len(search.Flights), string(metadata))
+	if err != nil {
+		return 0, fmt.Errorf("store: couldn't insert search: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("store: couldn't get search id: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO flights
+		(search_id, created_at, origin, destination, date, cabin_class, flight_id, is_nonstop, cash_price_usd, points_required, taxes_fees_usd, cpp, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("store: couldn't prepare flight insert: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+	for _, f := range search.Flights {
+		data, err := json.Marshal(f)
+		if err != nil {
+			return 0, fmt.Errorf("store: couldn't marshal flight: %w", err)
+		}
+		if _, err := stmt.ExecContext(ctx, id, created, search.Origin, search.Destination, search.Date,
+			search.CabinClass, f.ID(), f.IsNonstop, f.CashPriceUSD, f.PointsRequired, f.TaxesFeesUSD, f.CPP, string(data)); err != nil {
+			return 0, fmt.Errorf("store: couldn't insert flight %s: %w", f.ID(), err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("store: couldn't commit search: %w", err)
+	}
+	return id, nil
+}
+
+func (s *SQLite) History(ctx context.Context, q *HistoryQuery) ([]Observation, error) {
+	var where []string
+	var args []any
+	filters := []struct {
+		column string
+		value  string
+	}{
+		{"f.origin", strings.ToUpper(q.Origin)},
+		{"f.destination", strings.ToUpper(q.Destination)},
+		{"f.date", q.Date},
+		{"f.flight_id", q.FlightID},
+		{"f.cabin_class", strings.ToLower(q.CabinClass)},
+	}
+	for _, f := range filters {
+		if f.value == "" {
+			continue
+		}
+		where = append(where, f.column+" = ?")
+		args = append(args, f.value)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "f.created_at >= ?")
+		args = append(args, q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "f.created_at <= ?")
+		args = append(args, q.Until.UTC())
+	}
+	query := `SELECT f.search_id, f.created_at, s.source, s.trip_type, f.origin, f.destination, f.date, s.return_date,
+		s.passengers, f.cabin_class, f.flight_id, f.is_nonstop, f.cash_price_usd, f.points_required, f.taxes_fees_usd, f.cpp
+		FROM flights f JOIN searches s ON s.id = f.search_id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY f.created_at DESC, f.id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: couldn't query history: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var obs []Observation
+	for rows.Next() {
+		var o Observation
+		var cash, taxes, cpp sql.NullFloat64
+		var points sql.NullInt64
+		if err := rows.Scan(&o.SearchID, &o.Time, &o.Source, &o.TripType, &o.Origin, &o.Destination, &o.Date, &o.ReturnDate,
+			&o.Passengers, &o.CabinClass, &o.FlightID, &o.IsNonstop, &cash, &points, &taxes, &cpp); err != nil {
+			return nil, fmt.Errorf("store: couldn't scan history: %w", err)
+		}
+		o.CashPriceUSD = nullFloat(cash)
+		o.TaxesFeesUSD = nullFloat(taxes)
+		o.CPP = nullFloat(cpp)
+		if points.Valid {
+			p := int(points.Int64)
+			o.PointsRequired = &p
+		}
+		obs = append(obs, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: couldn't read history: %w", err)
+	}
+
+	// Sort by time, oldest first
+	slices.Reverse(obs)
+	return obs, nil
+}
+
+func nullFloat(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
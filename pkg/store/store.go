@@ -0,0 +1,96 @@
+// Package store persists searches and their flights to query price and award
+// trends over time.
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+)
+
+// Store persists searches and queries their history.
+type Store interface {
+	// SaveSearch stores a search with its flights and returns its ID.
+	SaveSearch(ctx context.Context, s *Search) (int64, error)
+	// History returns the stored prices of the flights matching the query,
+	// sorted by time.
+	History(ctx context.Context, q *HistoryQuery) ([]Observation, error)
+	Close() error
+}
+
+// Search is a search to be stored.
+type Search struct {
+	Time time.Time
+	// Source is the command that ran the search (e.g. search, calendar,
+	// watch or serve).
+	Source string
+	// TripType is one-way, round-trip or multi-city.
+	TripType    string
+	Origin      string
+	Destination string
+	Date        string
+	ReturnDate  string
+	Passengers  int
+	CabinClass  string
+	// Metadata is the search metadata, stored as JSON.
+	Metadata any
+	Flights  []aa.Flight
+}
+
+// Trip types of a search.
+const (
+	TripOneWay    = "one-way"
+	TripRoundTrip = "round-trip"
+	TripMultiCity = "multi-city"
+)
+
+// HistoryQuery filters the stored flights. Empty values match any flight.
+type HistoryQuery struct {
+	Origin      string
+	Destination string
+	Date        string
+	FlightID    string
+	CabinClass  string
+	Since       time.Time
+	Until       time.Time
+	// Limit is the maximum number of observations, keeping the latest ones.
+	Limit int
+}
+
+// Observation is the price of a flight seen in a search.
+type Observation struct {
+	SearchID       int64     `json:"search_id"`
+	Time           time.Time `json:"time"`
+	Source         string    `json:"source"`
+	TripType       string    `json:"trip_type"`
+	Origin         string    `json:"origin"`
+	Destination    string    `json:"destination"`
+	Date           string    `json:"date"`
+	ReturnDate     string    `json:"return_date,omitempty"`
+	Passengers     int       `json:"passengers"`
+	CabinClass     string    `json:"cabin_class"`
+	FlightID       string    `json:"flight_id"`
+	IsNonstop      bool      `json:"is_nonstop"`
+	CashPriceUSD   *float64  `json:"cash_price_usd"`
+	PointsRequired *int      `json:"points_required"`
+	TaxesFeesUSD   *float64  `json:"taxes_fees_usd"`
+	CPP            *float64  `json:"cpp"`
+}
+
+// Open opens a store from a spec with the backend and its location, such as
+// "sqlite:flyaa.db".
+func Open(spec string) (Store, error) {
+	backend, location, ok := strings.Cut(spec, ":")
+	if !ok || location == "" {
+		return nil, fmt.Errorf("store: invalid spec %q, expected backend:location (e.g. sqlite:flyaa.db)", spec)
+	}
+	switch backend {
+	case "sqlite":
+		return OpenSQLite(location)
+	default:
+		return nil, fmt.Errorf("store: unsupported backend %q, supported values are: sqlite", backend)
+	}
+}
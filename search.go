@@ -0,0 +1,576 @@
+package flyaa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+	"github.com/igolaizola/flyaa/pkg/store"
+	"golang.org/x/sync/errgroup"
+)
+
+// SearchParams contains the parameters of a search.
+type SearchParams struct {
+	// Client is the AA client used to search. It is required.
+	Client *aa.Client
+	// Store optionally persists the search and its flights, tagged with the
+	// source command.
+	Store  store.Store
+	Source string
+
+	Origin      string
+	Destination string
+	// Date is the flight date in YYYY-MM-DD format.
+	Date string
+	// ReturnDate is the optional return date of a round-trip search.
+	ReturnDate string
+	// Legs are the legs of a multi-city search in ORIGIN-DESTINATION:YYYY-MM-DD
+	// format. If set, origin, destination and dates are ignored.
+	Legs []string
+	// Passengers is the number of adults, used if no passenger type is set.
+	Passengers  int
+	Adults      int
+	Children    int
+	InfantsLap  int
+	InfantsSeat int
+	Seniors     int
+	CabinClass  string
+	// Join is how cash and award results are combined: inner (default), left,
+	// right or full.
+	Join        string
+	AllProducts bool
+	// Filter restricts the combined flights.
+	Filter Filter
+	// Sort are the sort keys of the flights in KEY[:asc|desc] format, in
+	// order of priority: cash, points, cpp, duration, departure or arrival.
+	Sort []string
+	// Limit is the maximum number of flights returned after sorting. Zero
+	// doesn't limit.
+	Limit int
+}
+
+// SearchMetadata describes the normalized parameters of a search.
+type SearchMetadata struct {
+	Origin         string         `json:"origin"`
+	Destination    string         `json:"destination"`
+	Date           string         `json:"date"`
+	ReturnDate     string         `json:"return_date,omitempty"`
+	Legs           []aa.Slice     `json:"legs,omitempty"`
+	Passengers     int            `json:"passengers"`
+	PassengerTypes []aa.Passenger `json:"passenger_types"`
+	CabinClass     string         `json:"cabin_class"`
+	Join           string         `json:"join"`
+	Cache          *CacheMetadata `json:"cache,omitempty"`
+}
+
+// CacheMetadata describes whether the cash and award results were read from
+// the cache. A search without results has no info.
+type CacheMetadata struct {
+	Cash  *aa.SearchInfo `json:"cash,omitempty"`
+	Award *aa.SearchInfo `json:"award,omitempty"`
+}
+
+// Result is the result of a search.
+type Result struct {
+	SearchMetadata SearchMetadata `json:"search_metadata"`
+	Flights        []aa.Flight    `json:"flights"`
+}
+
+// ErrInvalidParams is returned by Search when the search parameters are
+// invalid.
+var ErrInvalidParams = errors.New("invalid search params")
+
+// Search runs the cash and award searches and combines their flights.
+func Search(ctx context.Context, params SearchParams) (*Result, error) {
+	// Validate input
+	if params.Client == nil {
+		return nil, fmt.Errorf("client is required")
+	}
+	q, meta, err := params.query()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidParams, err)
+	}
+
+	// Search flights
+	flightsPrice, flightsPoints, cacheMeta, err := searchAll(ctx, params.Client, q)
+	if err != nil {
+		return nil, err
+	}
+	meta.Cache = cacheMeta
+	merged := merge(flightsPrice, flightsPoints, meta.Join)
+
+	// Store every flight of the search, before filtering them, so the
+	// history isn't limited to the displayed ones
+	if params.Store != nil {
+		if err := saveSearch(ctx, params.Store, params.Source, &Result{SearchMetadata: meta, Flights: merged}); err != nil {
+			log.Printf("couldn't store search: %v\n", err)
+		}
+	}
+
+	// Filter, sort and limit the flights
+	flights := params.Filter.apply(merged)
+	keys, _ := parseSort(params.Sort) // already validated
+	sortFlights(flights, keys)
+	if params.Limit > 0 && len(flights) > params.Limit {
+		flights = flights[:params.Limit]
+	}
+	return &Result{
+		SearchMetadata: meta,
+		Flights:        flights,
+	}, nil
+}
+
+// saveSearch stores the result of a search.
+func saveSearch(ctx context.Context, st store.Store, source string, r *Result) error {
+	meta := r.SearchMetadata
+	tripType := store.TripOneWay
+	switch {
+	case len(meta.Legs) > 0:
+		tripType = store.TripMultiCity
+	case meta.ReturnDate != "":
+		tripType = store.TripRoundTrip
+	}
+	if source == "" {
+		source = "search"
+	}
+	_, err := st.SaveSearch(ctx, &store.Search{
+		Time:        time.Now(),
+		Source:      source,
+		TripType:    tripType,
+		Origin:      meta.Origin,
+		Destination: meta.Destination,
+		Date:        meta.Date,
+		ReturnDate:  meta.ReturnDate,
+		Passengers:  meta.Passengers,
+		CabinClass:  meta.CabinClass,
+		Metadata:    meta,
+		Flights:     r.Flights,
+	})
+	return err
+}
+
+// maxLegs is the maximum number of legs of a multi-city search. The requests
+// of a search grow exponentially with the number of legs.
+const maxLegs = 6
+
+// query validates the parameters and returns the query of the search and
+// its normalized metadata.
+func (params SearchParams) query() (*aa.Query, SearchMetadata, error) {
+	var meta SearchMetadata
+	var searchSlices []aa.Slice
+	if len(params.Legs) > 0 {
+		if params.ReturnDate != "" {
+			return nil, meta, fmt.Errorf("return date can't be combined with legs")
+		}
+		if len(params.Legs) > maxLegs {
+			return nil, meta, fmt.Errorf("at most %d legs can be searched", maxLegs)
+		}
+		for _, l := range params.Legs {
+			leg, err := parseLeg(l)
+			if err != nil {
+				return nil, meta, err
+			}
+			if n := len(searchSlices); n > 0 && leg.Date < searchSlices[n-1].Date {
+				return nil, meta, fmt.Errorf("leg %q departs before the previous leg", l)
+			}
+			searchSlices = append(searchSlices, leg)
+		}
+	} else {
+		origin := strings.ToUpper(params.Origin)
+		if len(origin) != 3 {
+			return nil, meta, fmt.Errorf("origin airport code must be 3 letters")
+		}
+		destination := strings.ToUpper(params.Destination)
+		if len(destination) != 3 {
+			return nil, meta, fmt.Errorf("destination airport code must be 3 letters")
+		}
+		date := params.Date
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, meta, fmt.Errorf("flight date must be in YYYY-MM-DD format: %w", err)
+		}
+		searchSlices = append(searchSlices, aa.Slice{Origin: origin, Destination: destination, Date: date})
+		if returnDate := params.ReturnDate; returnDate != "" {
+			if _, err := time.Parse("2006-01-02", returnDate); err != nil {
+				return nil, meta, fmt.Errorf("return date must be in YYYY-MM-DD format: %w", err)
+			}
+			if returnDate < date {
+				return nil, meta, fmt.Errorf("return date %s is before flight date %s", returnDate, date)
+			}
+			searchSlices = append(searchSlices, aa.Slice{Origin: destination, Destination: origin, Date: returnDate})
+		}
+	}
+	passengerTypes, passengers, err := buildPassengers(&params)
+	if err != nil {
+		return nil, meta, err
+	}
+
+	join := strings.ToLower(params.Join)
+	switch join {
+	case "":
+		join = joinInner
+	case joinInner, joinLeft, joinRight, joinFull:
+	default:
+		return nil, meta, fmt.Errorf("unsupported join %q, supported values are: inner, left, right, full", join)
+	}
+
+	// Map cabin class
+	cabinClass := strings.ToLower(params.CabinClass)
+	productType, cabin, err := mapCabinClass(cabinClass)
+	if err != nil {
+		return nil, meta, err
+	}
+	if err := params.Filter.validate(); err != nil {
+		return nil, meta, err
+	}
+	if _, err := parseSort(params.Sort); err != nil {
+		return nil, meta, err
+	}
+	if params.Limit < 0 {
+		return nil, meta, fmt.Errorf("limit can't be negative")
+	}
+
+	// Build metadata
+	first, last := searchSlices[0], searchSlices[len(searchSlices)-1]
+	meta.Origin = first.Origin
+	meta.Destination = first.Destination
+	meta.Date = first.Date
+	if len(params.Legs) > 0 {
+		meta.Destination = last.Destination
+		meta.Legs = searchSlices
+	} else if len(searchSlices) > 1 {
+		meta.ReturnDate = last.Date
+	}
+	meta.Passengers = passengers
+	meta.PassengerTypes = passengerTypes
+	meta.CabinClass = cabinClass
+	meta.Join = join
+
+	return &aa.Query{
+		Slices:      searchSlices,
+		Passengers:  passengerTypes,
+		ProductType: productType,
+		Cabin:       cabin,
+		AllProducts: params.AllProducts,
+	}, meta, nil
+}
+
+// maxPassengers is the maximum number of passengers of a search.
+const maxPassengers = 9
+
+// buildPassengers returns the passengers of each type and the total number of
+// passengers. If no passenger type is set, the passengers are adults.
+func buildPassengers(params *SearchParams) ([]aa.Passenger, int, error) {
+	counts := []struct {
+		name  string
+		typ   string
+		count int
+	}{
+		{"adults", aa.PassengerAdult, params.Adults},
+		{"seniors", aa.PassengerSenior, params.Seniors},
+		{"children", aa.PassengerChild, params.Children},
+		{"infants in lap", aa.PassengerInfantLap, params.InfantsLap},
+		{"infants in seat", aa.PassengerInfantSeat, params.InfantsSeat},
+	}
+	var passengers []aa.Passenger
+	var total int
+	for _, c := range counts {
+		if c.count < 0 {
+			return nil, 0, fmt.Errorf("number of %s can't be negative", c.name)
+		}
+		if c.count == 0 {
+			continue
+		}
+		passengers = append(passengers, aa.Passenger{Type: c.typ, Count: c.count})
+		total += c.count
+	}
+	if total == 0 {
+		adults := params.Passengers
+		if adults <= 0 {
+			adults = 1
+		}
+		return []aa.Passenger{{Type: aa.PassengerAdult, Count: adults}}, adults, nil
+	}
+	if total > maxPassengers {
+		return nil, 0, fmt.Errorf("number of passengers can't be greater than %d", maxPassengers)
+	}
+	if params.Adults+params.Seniors == 0 {
+		return nil, 0, fmt.Errorf("at least one adult or senior is required")
+	}
+	if params.InfantsLap > params.Adults+params.Seniors {
+		return nil, 0, fmt.Errorf("each infant in lap requires an adult or senior")
+	}
+	return passengers, total, nil
+}
+
+// cabinClasses lists the supported cabin classes.
+var cabinClasses = []string{
+	"economy", "main", "main-plus",
+	"premium-economy", "premium-economy-flexible",
+	"business", "business-flexible",
+	"first", "first-flexible",
+}
+
+// mapCabinClass maps a cabin class to its AA product type and the cabin used
+// to search the slices. The cabin is empty for the main cabin classes.
+func mapCabinClass(cabinClass string) (string, string, error) {
+	switch cabinClass {
+	case "economy":
+		return "BASIC_ECONOMY", "", nil
+	case "main":
+		return "COACH", "", nil
+	case "main-plus":
+		return "COACH_FLEXIBLE", "", nil
+	case "premium-economy":
+		return "PREMIUM_ECONOMY", "PREMIUM_ECONOMY", nil
+	case "premium-economy-flexible":
+		return "PREMIUM_ECONOMY_FLEXIBLE", "PREMIUM_ECONOMY", nil
+	case "business":
+		return "BUSINESS", "BUSINESS", nil
+	case "business-flexible":
+		return "BUSINESS_FLEXIBLE", "BUSINESS", nil
+	case "first":
+		return "FIRST", "FIRST", nil
+	case "first-flexible":
+		return "FIRST_FLEXIBLE", "FIRST", nil
+	default:
+		return "", "", fmt.Errorf("unsupported cabin class %q, supported values are: %s", cabinClass, strings.Join(cabinClasses, ", "))
+	}
+}
+
+// searchAll runs the cash and the points searches of the query concurrently.
+// If only one of them has no availability, its flights are empty.
+func searchAll(ctx context.Context, svc *aa.Client, q *aa.Query) ([]aa.Flight, []aa.Flight, *CacheMetadata, error) {
+	var flightsPrice, flightsPoints []aa.Flight
+	var errPrice, errPoints error
+	var cacheMeta CacheMetadata
+
+	// Run both searches concurrently
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(2)
+	g.Go(func() error {
+		// Regular search
+		cashQuery := *q
+		cashQuery.RedeemPoints = false
+		fs, info, err := svc.SearchWithInfo(ctx, &cashQuery)
+		if errors.Is(err, aa.ErrNoAvailability) {
+			errPrice = err
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("search failed: %w", err)
+		}
+		flightsPrice = fs
+		cacheMeta.Cash = info
+		return nil
+	})
+	g.Go(func() error {
+		// Points search
+		pointsQuery := *q
+		pointsQuery.RedeemPoints = true
+		fs, info, err := svc.SearchWithInfo(ctx, &pointsQuery)
+		if errors.Is(err, aa.ErrNoAvailability) {
+			errPoints = err
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("search points failed: %w", err)
+		}
+		flightsPoints = fs
+		cacheMeta.Award = info
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, nil, nil, err
+	}
+	if errPrice != nil && errPoints != nil {
+		return nil, nil, nil, fmt.Errorf("search failed: %w", errPrice)
+	}
+	if cacheMeta.Cash == nil && cacheMeta.Award == nil {
+		return flightsPrice, flightsPoints, nil, nil
+	}
+	return flightsPrice, flightsPoints, &cacheMeta, nil
+}
+
+// Join modes used to combine the cash and the points search results.
+const (
+	joinInner = "inner"
+	joinLeft  = "left"
+	joinRight = "right"
+	joinFull  = "full"
+)
+
+// Reasons why the cents per point of a flight couldn't be calculated.
+const (
+	noCPPNoAwardPrice = "no_award_price"
+	noCPPNoCashPrice  = "no_cash_price"
+	noCPPZeroPoints   = "zero_points"
+)
+
+// merge combines the cash flights with their points pricing and calculates
+// the cents per point of each flight.
+// The inner join only keeps flights with both prices, the left join keeps all
+// cash flights, the right join keeps all points flights and the full join
+// keeps all of them.
+func merge(flightsPrice, flightsPoints []aa.Flight, join string) []aa.Flight {
+	lookupPrice := flightLookup(flightsPrice)
+	lookupPoints := flightLookup(flightsPoints)
+	var flights []aa.Flight
+	if join != joinRight {
+		for i := range flightsPrice {
+			fc := &flightsPrice[i]
+			fp := lookupPoints[fc.ID()]
+			if join == joinInner && (fp == nil || fp.PointsRequired == nil || *fp.PointsRequired == 0) {
+				continue
+			}
+			flights = append(flights, combine(fc, fp))
+		}
+	}
+	if join == joinRight || join == joinFull {
+		for i := range flightsPoints {
+			fp := &flightsPoints[i]
+			fc := lookupPrice[fp.ID()]
+			if fc != nil && join == joinFull {
+				// Already added with the cash flights
+				continue
+			}
+			flights = append(flights, combine(fc, fp))
+		}
+	}
+	return flights
+}
+
+// flightLookup indexes flights by their ID.
+func flightLookup(flights []aa.Flight) map[string]*aa.Flight {
+	lookup := make(map[string]*aa.Flight)
+	for i := range flights {
+		lookup[flights[i].ID()] = &flights[i]
+	}
+	return lookup
+}
+
+// combine creates a flight with the cash pricing of fc and the points pricing
+// of fp. Any of them can be nil if the flight wasn't found in that search.
+func combine(fc, fp *aa.Flight) aa.Flight {
+	var flight aa.Flight
+	var cashProducts, pointsProducts []aa.Product
+	if fc != nil {
+		flight = *fc
+		cashProducts = fc.Products
+	} else {
+		flight = *fp
+		flight.CashPriceUSD = nil
+	}
+	flight.PointsRequired = nil
+	flight.TaxesFeesUSD = nil
+	flight.TotalPointsRequired = nil
+	flight.TotalTaxesFeesUSD = nil
+	var cashPassengers, pointsPassengers []aa.PassengerPrice
+	if fc != nil {
+		cashPassengers = fc.PassengerPricing
+	} else {
+		flight.TotalCashPriceUSD = nil
+	}
+	if fp != nil {
+		flight.PointsRequired = fp.PointsRequired
+		flight.TaxesFeesUSD = fp.TaxesFeesUSD
+		flight.TotalPointsRequired = fp.TotalPointsRequired
+		flight.TotalTaxesFeesUSD = fp.TotalTaxesFeesUSD
+		pointsProducts = fp.Products
+		pointsPassengers = fp.PassengerPricing
+	}
+	// Use the totals so the CPP doesn't depend on how per passenger prices
+	// are averaged
+	flight.CPP, flight.NoCPPReason = cpp(flight.TotalCashPriceUSD, flight.TotalPointsRequired, flight.TotalTaxesFeesUSD)
+	flight.Products = mergeProducts(cashProducts, pointsProducts)
+	flight.PassengerPricing = mergePassengerPricing(cashPassengers, pointsPassengers)
+	return flight
+}
+
+// mergePassengerPricing combines the cash and the points pricing of each
+// passenger type.
+func mergePassengerPricing(pricesCash, pricesPoints []aa.PassengerPrice) []aa.PassengerPrice {
+	var prices []aa.PassengerPrice
+	index := make(map[string]int)
+	for _, p := range pricesCash {
+		index[p.Type] = len(prices)
+		prices = append(prices, p)
+	}
+	for _, p := range pricesPoints {
+		i, ok := index[p.Type]
+		if !ok {
+			index[p.Type] = len(prices)
+			prices = append(prices, p)
+			continue
+		}
+		prices[i].PointsRequired = p.PointsRequired
+		prices[i].TaxesFeesUSD = p.TaxesFeesUSD
+	}
+	return prices
+}
+
+// mergeProducts combines the cash and the points pricing of each product type
+// and calculates the cents per point of each of them.
+func mergeProducts(productsPrice, productsPoints []aa.Product) []aa.Product {
+	var products []aa.Product
+	index := make(map[string]int)
+	for _, p := range productsPrice {
+		index[p.ProductType] = len(products)
+		products = append(products, p)
+	}
+	for _, p := range productsPoints {
+		i, ok := index[p.ProductType]
+		if !ok {
+			index[p.ProductType] = len(products)
+			products = append(products, p)
+			continue
+		}
+		products[i].PointsRequired = p.PointsRequired
+		products[i].TaxesFeesUSD = p.TaxesFeesUSD
+		products[i].AwardSolutionID = p.AwardSolutionID
+	}
+	for i := range products {
+		p := &products[i]
+		p.CPP, p.NoCPPReason = cpp(p.CashPriceUSD, p.PointsRequired, p.TaxesFeesUSD)
+	}
+	return products
+}
+
+// cpp calculates the cents per point of a pricing or returns the reason why it
+// couldn't be calculated.
+func cpp(cashPrice *float64, pointsRequired *int, taxesFees *float64) (*float64, string) {
+	switch {
+	case cashPrice == nil:
+		return nil, noCPPNoCashPrice
+	case pointsRequired == nil:
+		return nil, noCPPNoAwardPrice
+	case *pointsRequired == 0:
+		return nil, noCPPZeroPoints
+	}
+	var taxes float64
+	if taxesFees != nil {
+		taxes = *taxesFees
+	}
+	v := (*cashPrice - taxes) / float64(*pointsRequired) * 100.0
+	v = round(v, 2)
+	return &v, ""
+}
+
+// parseLeg parses a leg in the "LAX-JFK:2025-12-15" format.
+func parseLeg(s string) (aa.Slice, error) {
+	route, date, ok := strings.Cut(s, ":")
+	if !ok {
+		return aa.Slice{}, fmt.Errorf("leg %q must be in ORIGIN-DESTINATION:YYYY-MM-DD format", s)
+	}
+	origin, destination, ok := strings.Cut(strings.ToUpper(route), "-")
+	if !ok || len(origin) != 3 || len(destination) != 3 {
+		return aa.Slice{}, fmt.Errorf("leg %q airport codes must be 3 letters", s)
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return aa.Slice{}, fmt.Errorf("leg %q date must be in YYYY-MM-DD format: %w", s, err)
+	}
+	return aa.Slice{Origin: origin, Destination: destination, Date: date}, nil
+}
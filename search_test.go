@@ -0,0 +1,266 @@
+package flyaa
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+	"github.com/igolaizola/flyaa/pkg/fakeaa"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// testFlight returns a nonstop flight with the given flight number and
+// pricing. Nil prices are missing.
+func testFlight(number string, cash *float64, points *int, taxes *float64) aa.Flight {
+	f := aa.Flight{
+		IsNonstop:      true,
+		Segments:       []aa.FlightSegment{{FlightNumber: number}},
+		CashPriceUSD:   cash,
+		PointsRequired: points,
+		TaxesFeesUSD:   taxes,
+	}
+	f.TotalCashPriceUSD = cash
+	f.TotalPointsRequired = points
+	f.TotalTaxesFeesUSD = taxes
+	return f
+}
+
+func TestMerge(t *testing.T) {
+	cash := []aa.Flight{
+		testFlight("AA1", ptr(200.0), nil, nil),
+		testFlight("AA2", ptr(300.0), nil, nil),
+	}
+	points := []aa.Flight{
+		testFlight("AA2", nil, ptr(20000), ptr(5.6)),
+		testFlight("AA3", nil, ptr(15000), ptr(5.6)),
+		testFlight("AA4", nil, ptr(0), ptr(5.6)),
+	}
+	tests := []struct {
+		join string
+		want []string
+	}{
+		{joinInner, []string{"AA2"}},
+		{joinLeft, []string{"AA1", "AA2"}},
+		{joinRight, []string{"AA2", "AA3", "AA4"}},
+		{joinFull, []string{"AA1", "AA2", "AA3", "AA4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.join, func(t *testing.T) {
+			flights := merge(cash, points, tt.join)
+			var ids []string
+			for _, f := range flights {
+				ids = append(ids, f.ID())
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Fatalf("got flights %v, want %v", ids, tt.want)
+			}
+			for _, f := range flights {
+				var wantReason string
+				switch f.ID() {
+				case "AA1":
+					wantReason = noCPPNoAwardPrice
+				case "AA2":
+					if f.CashPriceUSD == nil || *f.CashPriceUSD != 300 || f.PointsRequired == nil || *f.PointsRequired != 20000 {
+						t.Errorf("AA2: prices weren't combined: %+v", f)
+					}
+				case "AA3":
+					wantReason = noCPPNoCashPrice
+				case "AA4":
+					wantReason = noCPPNoCashPrice
+				}
+				if f.NoCPPReason != wantReason {
+					t.Errorf("%s: got no cpp reason %q, want %q", f.ID(), f.NoCPPReason, wantReason)
+				}
+				if (f.CPP == nil) != (wantReason != "") {
+					t.Errorf("%s: got cpp %v with reason %q", f.ID(), f.CPP, f.NoCPPReason)
+				}
+			}
+		})
+	}
+}
+
+func TestCPP(t *testing.T) {
+	tests := []struct {
+		name       string
+		cash       *float64
+		points     *int
+		taxes      *float64
+		want       *float64
+		wantReason string
+	}{
+		{name: "with taxes", cash: ptr(199.0), points: ptr(12500), taxes: ptr(5.6), want: ptr(1.55)},
+		{name: "without taxes", cash: ptr(100.0), points: ptr(10000), want: ptr(1.0)},
+		{name: "no cash price", points: ptr(10000), taxes: ptr(5.6), wantReason: noCPPNoCashPrice},
+		{name: "no award price", cash: ptr(100.0), wantReason: noCPPNoAwardPrice},
+		{name: "zero points", cash: ptr(100.0), points: ptr(0), wantReason: noCPPZeroPoints},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := cpp(tt.cash, tt.points, tt.taxes)
+			if reason != tt.wantReason {
+				t.Errorf("got reason %q, want %q", reason, tt.wantReason)
+			}
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("got cpp %v, want nil", *got)
+			case tt.want != nil && (got == nil || *got != *tt.want):
+				t.Errorf("got cpp %v, want %v", got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeProducts(t *testing.T) {
+	cash := []aa.Product{
+		{ProductType: "COACH", CashPriceUSD: ptr(199.0), CashSolutionID: "c1"},
+		{ProductType: "BASIC_ECONOMY", CashPriceUSD: ptr(159.0), CashSolutionID: "c2"},
+	}
+	points := []aa.Product{
+		{ProductType: "COACH", PointsRequired: ptr(12500), TaxesFeesUSD: ptr(5.6), AwardSolutionID: "a1"},
+		{ProductType: "BUSINESS", PointsRequired: ptr(57500), TaxesFeesUSD: ptr(5.6), AwardSolutionID: "a2"},
+	}
+	products := mergeProducts(cash, points)
+	want := []struct {
+		productType string
+		cpp         *float64
+		reason      string
+	}{
+		{"COACH", ptr(1.55), ""},
+		{"BASIC_ECONOMY", nil, noCPPNoAwardPrice},
+		{"BUSINESS", nil, noCPPNoCashPrice},
+	}
+	if len(products) != len(want) {
+		t.Fatalf("got %d products, want %d", len(products), len(want))
+	}
+	for i, w := range want {
+		p := products[i]
+		if p.ProductType != w.productType {
+			t.Errorf("product %d: got %s, want %s", i, p.ProductType, w.productType)
+		}
+		if p.NoCPPReason != w.reason {
+			t.Errorf("%s: got reason %q, want %q", p.ProductType, p.NoCPPReason, w.reason)
+		}
+		if (p.CPP == nil) != (w.cpp == nil) || (p.CPP != nil && *p.CPP != *w.cpp) {
+			t.Errorf("%s: got cpp %v, want %v", p.ProductType, p.CPP, w.cpp)
+		}
+	}
+	if coach := products[0]; coach.CashSolutionID != "c1" || coach.AwardSolutionID != "a1" {
+		t.Errorf("COACH: solutions weren't combined: %+v", coach)
+	}
+}
+
+func TestSearchMixedParty(t *testing.T) {
+	srv := httptest.NewServer(fakeaa.NewServer(nil))
+	defer srv.Close()
+	client, err := aa.New(&aa.Config{BaseURL: srv.URL, Doer: srv.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Infants in lap fly for free in the fake server, so the per passenger
+	// prices are half the ones of a seat but the CPP doesn't change
+	tests := []struct {
+		name                string
+		adults, infantsLap  int
+		wantCash, wantTaxes float64
+		wantPoints          int
+		wantTotalCash       float64
+		wantTotalPoints     int
+		wantCPP             float64
+	}{
+		{name: "adult", adults: 1, wantCash: 199, wantPoints: 12500, wantTaxes: 5.6, wantTotalCash: 199, wantTotalPoints: 12500, wantCPP: 1.55},
+		{name: "adult and infant in lap", adults: 1, infantsLap: 1, wantCash: 99.5, wantPoints: 6250, wantTaxes: 2.8, wantTotalCash: 199, wantTotalPoints: 12500, wantCPP: 1.55},
+		{name: "two adults", adults: 2, wantCash: 199, wantPoints: 12500, wantTaxes: 5.6, wantTotalCash: 398, wantTotalPoints: 25000, wantCPP: 1.55},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Search(context.Background(), SearchParams{
+				Client:      client,
+				Origin:      "LAX",
+				Destination: "JFK",
+				Date:        "2025-12-15",
+				Adults:      tt.adults,
+				InfantsLap:  tt.infantsLap,
+				CabinClass:  "main",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := slices.IndexFunc(r.Flights, func(f aa.Flight) bool { return f.ID() == "AA100" })
+			if i < 0 {
+				t.Fatal("flight AA100 not found")
+			}
+			f := r.Flights[i]
+			floats := []struct {
+				name string
+				got  *float64
+				want float64
+			}{
+				{"cash", f.CashPriceUSD, tt.wantCash},
+				{"taxes", f.TaxesFeesUSD, tt.wantTaxes},
+				{"total cash", f.TotalCashPriceUSD, tt.wantTotalCash},
+				{"cpp", f.CPP, tt.wantCPP},
+			}
+			for _, c := range floats {
+				if c.got == nil || *c.got != c.want {
+					t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+				}
+			}
+			ints := []struct {
+				name string
+				got  *int
+				want int
+			}{
+				{"points", f.PointsRequired, tt.wantPoints},
+				{"total points", f.TotalPointsRequired, tt.wantTotalPoints},
+			}
+			for _, c := range ints {
+				if c.got == nil || *c.got != c.want {
+					t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchMaxLegs(t *testing.T) {
+	legs := []string{
+		"LAX-JFK:2025-12-15", "JFK-MIA:2025-12-16", "MIA-ORD:2025-12-17",
+		"ORD-DFW:2025-12-18", "DFW-PHX:2025-12-19", "PHX-SEA:2025-12-20",
+		"SEA-LAX:2025-12-21",
+	}
+	client, err := aa.New(&aa.Config{BaseURL: "http://localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		legs    []string
+		wantErr bool
+	}{
+		{name: "max legs", legs: legs[:maxLegs]},
+		{name: "over max legs", legs: legs, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := SearchParams{Client: client, Legs: tt.legs, Adults: 1, CabinClass: "main"}
+			_, _, err := params.query()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+			// Search fails before sending any request
+			if _, err := Search(context.Background(), params); !errors.Is(err, ErrInvalidParams) {
+				t.Errorf("got error %v, want %v", err, ErrInvalidParams)
+			}
+		})
+	}
+}
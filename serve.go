@@ -0,0 +1,186 @@
+package flyaa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+)
+
+type ServeConfig struct {
+	Config
+	Addr string
+}
+
+// Serve exposes the search as a REST API until the context is canceled.
+// Search parameters that aren't set in a request use the values of the
+// config.
+func Serve(ctx context.Context, cfg *ServeConfig) error {
+	// Validate input
+	if cfg.BaseURL == "" && cfg.Replay == "" {
+		return fmt.Errorf("base URL is required")
+	}
+
+	// Create service client
+	svc, err := newClient(&cfg.Config)
+	if err != nil {
+		return err
+	}
+	defer logProxyStats(svc)
+	st, err := openStore(&cfg.Config)
+	if err != nil {
+		return err
+	}
+	defer closeStore(st)
+
+	defaults := cfg.SearchParams
+	defaults.Client = svc
+	defaults.Store = st
+	defaults.Source = "serve"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /search", func(w http.ResponseWriter, r *http.Request) {
+		params, err := searchParamsFromQuery(defaults, r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		resp, err := Search(r.Context(), params)
+		if err != nil {
+			log.Printf("search %s failed: %v\n", r.URL.RawQuery, err)
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+	return listenAndServe(ctx, cfg.Addr, "server", mux)
+}
+
+// searchParamsFromQuery returns the search parameters of the query string,
+// using the defaults for the missing ones.
+func searchParamsFromQuery(defaults SearchParams, q url.Values) (SearchParams, error) {
+	params := defaults
+	strs := []struct {
+		key   string
+		value *string
+	}{
+		{"origin", &params.Origin},
+		{"destination", &params.Destination},
+		{"date", &params.Date},
+		{"return-date", &params.ReturnDate},
+		{"cabin-class", &params.CabinClass},
+		{"cabin", &params.CabinClass},
+		{"join", &params.Join},
+	}
+	for _, s := range strs {
+		if q.Has(s.key) {
+			*s.value = q.Get(s.key)
+		}
+	}
+	if q.Has("return-date") || q.Has("origin") || q.Has("destination") || q.Has("date") {
+		params.Legs = nil
+	}
+	if legs, ok := q["leg"]; ok {
+		params.Legs = legs
+	}
+
+	ints := []struct {
+		key   string
+		value *int
+	}{
+		{"passengers", &params.Passengers},
+		{"adults", &params.Adults},
+		{"children", &params.Children},
+		{"infants-lap", &params.InfantsLap},
+		{"infants-seat", &params.InfantsSeat},
+		{"seniors", &params.Seniors},
+	}
+	for _, i := range ints {
+		if !q.Has(i.key) {
+			continue
+		}
+		v, err := strconv.Atoi(q.Get(i.key))
+		if err != nil {
+			return SearchParams{}, fmt.Errorf("%w: %s must be a number", ErrInvalidParams, i.key)
+		}
+		*i.value = v
+	}
+	if q.Has("all-products") {
+		v, err := strconv.ParseBool(q.Get("all-products"))
+		if err != nil {
+			return SearchParams{}, fmt.Errorf("%w: all-products must be a boolean", ErrInvalidParams)
+		}
+		params.AllProducts = v
+	}
+	return params, nil
+}
+
+// errorStatus returns the HTTP status code of a search error.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidParams):
+		return http.StatusBadRequest
+	case errors.Is(err, aa.ErrNoAvailability):
+		return http.StatusNotFound
+	case errors.Is(err, aa.ErrInvalidMarket):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, context.Canceled):
+		return 499
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("couldn't marshal response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(data, '\n'))
+}
+
+// listenAndServe serves the handler on the address until the context is
+// canceled, then shuts down the server gracefully.
+func listenAndServe(ctx context.Context, addr, name string, handler http.Handler) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("couldn't listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errC := make(chan error, 1)
+	go func() {
+		errC <- srv.Serve(ln)
+	}()
+	log.Printf("%s listening on http://%s/\n", name, ln.Addr())
+
+	// Wait until the context is canceled or the server fails
+	select {
+	case err := <-errC:
+		return fmt.Errorf("%s failed: %w", name, err)
+	case <-ctx.Done():
+	}
+	log.Printf("%s shutting down\n", name)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("couldn't shutdown %s: %w", name, err)
+	}
+	return nil
+}
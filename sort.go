@@ -0,0 +1,150 @@
+package flyaa
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+)
+
+// Sort keys of the flights.
+const (
+	sortCash      = "cash"
+	sortPoints    = "points"
+	sortCPP       = "cpp"
+	sortDuration  = "duration"
+	sortDeparture = "departure"
+	sortArrival   = "arrival"
+)
+
+// sortKeys lists the supported sort keys.
+var sortKeys = []string{sortCash, sortPoints, sortCPP, sortDuration, sortDeparture, sortArrival}
+
+// sortKey is a sort key and its direction.
+type sortKey struct {
+	name string
+	desc bool
+}
+
+// parseSort parses sort keys in KEY[:asc|desc] format. Values can contain
+// several comma separated keys.
+func parseSort(values []string) ([]sortKey, error) {
+	var keys []sortKey
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.ToLower(strings.TrimSpace(s))
+			if s == "" {
+				continue
+			}
+			name, dir, _ := strings.Cut(s, ":")
+			if !slices.Contains(sortKeys, name) {
+				return nil, fmt.Errorf("unsupported sort key %q, supported values are: %s", name, strings.Join(sortKeys, ", "))
+			}
+			key := sortKey{name: name}
+			switch dir {
+			case "", "asc":
+			case "desc":
+				key.desc = true
+			default:
+				return nil, fmt.Errorf("unsupported sort direction %q, supported values are: asc, desc", dir)
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// sortFlights sorts the flights by the keys, in order of priority. Flights
+// without a value for a key go last in both directions, and ties keep their
+// original order.
+func sortFlights(flights []aa.Flight, keys []sortKey) {
+	if len(keys) == 0 {
+		return
+	}
+	slices.SortStableFunc(flights, func(a, b aa.Flight) int {
+		for _, k := range keys {
+			va, okA := sortValue(&a, k.name)
+			vb, okB := sortValue(&b, k.name)
+			switch {
+			case !okA && !okB:
+				continue
+			case !okA:
+				return 1
+			case !okB:
+				return -1
+			}
+			c := compareFloat(va, vb)
+			if k.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// sortValue returns the value of a flight for a sort key and whether the
+// flight has it. Durations are in minutes and times are unix timestamps.
+func sortValue(f *aa.Flight, key string) (float64, bool) {
+	switch key {
+	case sortCash:
+		if f.CashPriceUSD == nil {
+			return 0, false
+		}
+		return *f.CashPriceUSD, true
+	case sortPoints:
+		if f.PointsRequired == nil {
+			return 0, false
+		}
+		return float64(*f.PointsRequired), true
+	case sortCPP:
+		if f.CPP == nil {
+			return 0, false
+		}
+		return *f.CPP, true
+	case sortDuration:
+		// Durations are in "5h 30m" format
+		d, err := time.ParseDuration(strings.ReplaceAll(f.TotalDuration, " ", ""))
+		if err != nil {
+			return 0, false
+		}
+		return d.Minutes(), true
+	case sortDeparture, sortArrival:
+		segments := f.Segments
+		if len(f.Legs) > 0 {
+			segments = f.Legs[0].Segments
+			if key == sortArrival {
+				segments = f.Legs[len(f.Legs)-1].Segments
+			}
+		}
+		if len(segments) == 0 {
+			return 0, false
+		}
+		v := segments[0].DepartureDateTime
+		if key == sortArrival {
+			v = segments[len(segments)-1].ArrivalDateTime
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return 0, false
+		}
+		return float64(t.Unix()), true
+	default:
+		return 0, false
+	}
+}
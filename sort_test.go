@@ -0,0 +1,86 @@
+package flyaa
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []string
+		want    []sortKey
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "single", in: []string{"cash"}, want: []sortKey{{name: sortCash}}},
+		{name: "directions", in: []string{"points:desc", "cpp:ASC"}, want: []sortKey{{name: sortPoints, desc: true}, {name: sortCPP}}},
+		{name: "comma separated", in: []string{"duration, departure:desc,"}, want: []sortKey{{name: sortDuration}, {name: sortDeparture, desc: true}}},
+		{name: "comma separated and repeated", in: []string{"cash,points", "arrival:desc"}, want: []sortKey{{name: sortCash}, {name: sortPoints}, {name: sortArrival, desc: true}}},
+		{name: "invalid key", in: []string{"price"}, wantErr: true},
+		{name: "invalid direction", in: []string{"cash:up"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSort(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortFlights(t *testing.T) {
+	withTimes := func(f aa.Flight, departure, arrival, duration string) aa.Flight {
+		f.Segments[0].DepartureDateTime = departure
+		f.Segments[0].ArrivalDateTime = arrival
+		f.TotalDuration = duration
+		return f
+	}
+	flights := []aa.Flight{
+		withTimes(testFlight("AA1", ptr(300.0), ptr(20000), nil), "2025-12-15T08:00:00-08:00", "2025-12-15T16:30:00-05:00", "5h 30m"),
+		withTimes(testFlight("AA2", nil, ptr(15000), nil), "2025-12-15T06:00:00-08:00", "2025-12-15T15:00:00-05:00", "6h 0m"),
+		withTimes(testFlight("AA3", ptr(200.0), ptr(20000), nil), "2025-12-15T12:00:00-08:00", "", "5h 30m"),
+		withTimes(testFlight("AA4", ptr(200.0), nil, nil), "", "2025-12-15T23:00:00-05:00", ""),
+	}
+	flights[0].CPP = ptr(1.5)
+	flights[2].CPP = ptr(1.0)
+	tests := []struct {
+		name string
+		sort []string
+		want []string
+	}{
+		{name: "none", want: []string{"AA1", "AA2", "AA3", "AA4"}},
+		{name: "cash missing last", sort: []string{"cash"}, want: []string{"AA3", "AA4", "AA1", "AA2"}},
+		{name: "cash desc missing last", sort: []string{"cash:desc"}, want: []string{"AA1", "AA3", "AA4", "AA2"}},
+		{name: "points stable ties", sort: []string{"points"}, want: []string{"AA2", "AA1", "AA3", "AA4"}},
+		{name: "cpp desc", sort: []string{"cpp:desc"}, want: []string{"AA1", "AA3", "AA2", "AA4"}},
+		{name: "duration", sort: []string{"duration"}, want: []string{"AA1", "AA3", "AA2", "AA4"}},
+		{name: "departure", sort: []string{"departure"}, want: []string{"AA2", "AA1", "AA3", "AA4"}},
+		{name: "arrival desc", sort: []string{"arrival:desc"}, want: []string{"AA4", "AA1", "AA2", "AA3"}},
+		{name: "multiple keys", sort: []string{"points,cash"}, want: []string{"AA2", "AA3", "AA1", "AA4"}},
+		{name: "multiple keys desc", sort: []string{"cash", "points:desc"}, want: []string{"AA3", "AA4", "AA1", "AA2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseSort(tt.sort)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sorted := slices.Clone(flights)
+			sortFlights(sorted, keys)
+			var got []string
+			for _, f := range sorted {
+				got = append(got, f.ID())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package flyaa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/igolaizola/flyaa/pkg/aa"
+)
+
+type WatchConfig struct {
+	Config
+	Interval time.Duration
+	// MaxCash, MaxPoints and MinCPP are the alert thresholds. Zero values
+	// disable them.
+	MaxCash   float64
+	MaxPoints int
+	MinCPP    float64
+	// Webhook is an optional URL where alerts are posted as JSON.
+	Webhook string
+	// Exec is an optional shell command run for each alert, with the alert
+	// as JSON in its standard input.
+	Exec string
+}
+
+// Alert is fired when a flight matches the thresholds of a watch.
+type Alert struct {
+	Time           time.Time      `json:"time"`
+	SearchMetadata SearchMetadata `json:"search_metadata"`
+	FlightID       string         `json:"flight_id"`
+	Reasons        []string       `json:"reasons"`
+	Flight         aa.Flight      `json:"flight"`
+}
+
+// webhookTimeout is the maximum time to send an alert to the webhook.
+const webhookTimeout = 10 * time.Second
+
+// watchState is the last alerted prices of a flight.
+type watchState struct {
+	cash   *float64
+	points *int
+	cpp    *float64
+}
+
+// Watch runs the search on an interval and fires alerts when flights match
+// the thresholds. A flight is alerted again only if its prices change or if
+// it stops matching and matches again later.
+func Watch(ctx context.Context, cfg *WatchConfig) error {
+	// Validate input
+	if cfg.BaseURL == "" && cfg.Replay == "" {
+		return fmt.Errorf("base URL is required")
+	}
+	if cfg.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if cfg.MaxCash <= 0 && cfg.MaxPoints <= 0 && cfg.MinCPP <= 0 {
+		return fmt.Errorf("at least one threshold (max cash, max points or min cpp) is required")
+	}
+	params := cfg.SearchParams
+	if _, _, err := params.query(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidParams, err)
+	}
+
+	// Create service client, always refreshing cached results
+	clientCfg := cfg.Config
+	clientCfg.Refresh = true
+	svc, err := newClient(&clientCfg)
+	if err != nil {
+		return err
+	}
+	defer logProxyStats(svc)
+	st, err := openStore(&cfg.Config)
+	if err != nil {
+		return err
+	}
+	defer closeStore(st)
+	params.Client = svc
+	params.Store = st
+	params.Source = "watch"
+
+	previous := map[string]watchState{}
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		resp, err := Search(ctx, params)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			log.Printf("watch: search failed: %v\n", err)
+		default:
+			alerts := watchAlerts(cfg, resp, previous)
+			for _, a := range alerts {
+				if err := sendAlert(ctx, cfg, a); err != nil {
+					log.Printf("watch: %v\n", err)
+				}
+			}
+			log.Printf("watch: %d flights, %d alerts, next search at %s\n", len(resp.Flights), len(alerts), time.Now().Add(cfg.Interval).Format(time.TimeOnly))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchAlerts returns the alerts of the flights that match the thresholds
+// and updates the previous state with them.
+func watchAlerts(cfg *WatchConfig, resp *Result, previous map[string]watchState) []Alert {
+	now := time.Now().UTC()
+	var alerts []Alert
+	current := map[string]watchState{}
+	for _, f := range resp.Flights {
+		var reasons []string
+		if cfg.MaxCash > 0 && f.CashPriceUSD != nil && *f.CashPriceUSD < cfg.MaxCash {
+			reasons = append(reasons, fmt.Sprintf("cash price %.2f USD below %.2f", *f.CashPriceUSD, cfg.MaxCash))
+		}
+		if cfg.MaxPoints > 0 && f.PointsRequired != nil && *f.PointsRequired > 0 && *f.PointsRequired < cfg.MaxPoints {
+			reasons = append(reasons, fmt.Sprintf("points %d below %d", *f.PointsRequired, cfg.MaxPoints))
+		}
+		if cfg.MinCPP > 0 && f.CPP != nil && *f.CPP > cfg.MinCPP {
+			reasons = append(reasons, fmt.Sprintf("cpp %.2f above %.2f", *f.CPP, cfg.MinCPP))
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+		id := f.ID()
+		state := watchState{cash: f.CashPriceUSD, points: f.PointsRequired, cpp: f.CPP}
+		current[id] = state
+		if prev, ok := previous[id]; ok && prev.equal(state) {
+			continue
+		}
+		alerts = append(alerts, Alert{
+			Time:           now,
+			SearchMetadata: resp.SearchMetadata,
+			FlightID:       id,
+			Reasons:        reasons,
+			Flight:         f,
+		})
+	}
+
+	// Forget the flights that don't match anymore
+	clear(previous)
+	for id, s := range current {
+		previous[id] = s
+	}
+	return alerts
+}
+
+func (s watchState) equal(o watchState) bool {
+	return equalPtr(s.cash, o.cash) && equalPtr(s.points, o.points) && equalPtr(s.cpp, o.cpp)
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// sendAlert prints the alert to stdout and sends it to the webhook and the
+// command if they are configured.
+func sendAlert(ctx context.Context, cfg *WatchConfig, a Alert) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal alert: %w", err)
+	}
+	fmt.Println(string(data))
+
+	if cfg.Webhook != "" {
+		// Don't let a hanging webhook block the watch
+		ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.Webhook, bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("couldn't create webhook request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("couldn't send webhook: %w", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		}
+	}
+
+	if cfg.Exec != "" {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", cfg.Exec)
+		} else {
+			cmd = exec.CommandContext(ctx, "sh", "-c", cfg.Exec)
+		}
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(),
+			"FLYAA_ALERT_FLIGHT_ID="+a.FlightID,
+			"FLYAA_ALERT_ORIGIN="+a.SearchMetadata.Origin,
+			"FLYAA_ALERT_DESTINATION="+a.SearchMetadata.Destination,
+			"FLYAA_ALERT_DATE="+a.SearchMetadata.Date,
+		)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("couldn't run alert command: %w", err)
+		}
+	}
+	return nil
+}